@@ -13,13 +13,20 @@ import (
 //
 // Usage: aws.New().NewS3Operation(opts ...S3Options)
 type AWS struct {
-	sess   *session.Session
-	region string
+	sess     *session.Session
+	region   string
+	settings *config.Settings
 }
 
 // New will create a new AWS packages
 func New(region string) *AWS {
-	a := new(AWS)
+	return NewWithSettings(region, nil)
+}
+
+// NewWithSettings will create a new AWS packages using the credentials of the provided config.Settings.
+// A nil settings falls back to config.Default().
+func NewWithSettings(region string, settings *config.Settings) *AWS {
+	a := &AWS{settings: config.OrDefault(settings)}
 	if region != "" {
 		a.region = region
 		return a.ConnectAws()
@@ -34,7 +41,7 @@ func (a *AWS) Raw() *session.Session {
 }
 
 // ConnectAws establishes an AWS session by creating a new session with the given AWS region.
-// It uses the credentials from the GetAWSConfig function if it is available.
+// It uses the credentials from the AWSConfig of its config.Settings if it is available.
 // If there is an error during session creation, a panic is raised.
 // It returns the created AWS session.
 func (a *AWS) ConnectAws() *AWS {
@@ -42,8 +49,8 @@ func (a *AWS) ConnectAws() *AWS {
 	cfg := &aws.Config{
 		Region: aws.String(a.region),
 	}
-	if config.GetAWSConfig() != nil {
-		cfg.Credentials = credentials.NewStaticCredentials(config.GetAWSConfig().AccessKeyID, config.GetAWSConfig().SecretAccessKey, "")
+	if awsConfig := config.OrDefault(a.settings).GetAWSConfig(); awsConfig != nil {
+		cfg.Credentials = credentials.NewStaticCredentials(awsConfig.AccessKeyID, awsConfig.SecretAccessKey, "")
 	}
 	a.sess, err = session.NewSession(cfg)
 	if err != nil {
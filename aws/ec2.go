@@ -315,7 +315,7 @@ func (e *EC2Stopper) Stop(opts ...Opts) error {
 	if err != nil {
 		return err
 	}
-	logger.Info(out.String())
+	logger.Info("%s", out.String())
 	return nil
 }
 
@@ -0,0 +1,135 @@
+// Command configdoc generates the Markdown documentation or the `.env.example` template of the config
+// structs shipped by go-common, using the same prefixing rules as config.LoadConfig.
+//
+// Every -type flag adds one config struct under an optional prefix, mirroring a service config such as
+//
+//	type Config struct {
+//	    Database config.DatabaseConfig `prefix:"DB"`
+//	    Redis    config.RedisConfig    `prefix:"REDIS"`
+//	}
+//
+// Usage:
+//
+//	go run github.com/shiroyaavish/go-common/cmd/configdoc -type DB=DatabaseConfig -type REDIS=RedisConfig -format env -out .env.example
+//
+// Service specific structs are documented by calling config.GenerateMarkdown or config.GenerateEnvExample
+// from a small generator inside the service, e.g. behind a `//go:generate` directive.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/shiroyaavish/go-common/config"
+)
+
+// knownTypes are the config structs that can be referenced with -type.
+var knownTypes = map[string]reflect.Type{
+	"DefaultSettings": reflect.TypeOf(config.DefaultSettings{}),
+	"AWSConfig":       reflect.TypeOf(config.AWSConfig{}),
+	"DatabaseConfig":  reflect.TypeOf(config.DatabaseConfig{}),
+	"GRPCConfig":      reflect.TypeOf(config.GRPCConfig{}),
+	"RedisConfig":     reflect.TypeOf(config.RedisConfig{}),
+	"StripeConfig":    reflect.TypeOf(config.StripeConfig{}),
+	"WrapperConfig":   reflect.TypeOf(config.WrapperConfig{}),
+	"ServerConfig":    reflect.TypeOf(config.ServerConfig{}),
+	"CashFreeConfig":  reflect.TypeOf(config.CashFreeConfig{}),
+	"RabbitMQConfig":  reflect.TypeOf(config.RabbitMQConfig{}),
+}
+
+// typeFlags collects the repeated -type flags.
+type typeFlags []string
+
+func (t *typeFlags) String() string {
+	return strings.Join(*t, ",")
+}
+
+func (t *typeFlags) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+func main() {
+	var types typeFlags
+	flag.Var(&types, "type", "config struct to document as [PREFIX=]TypeName, can be repeated ("+strings.Join(typeNames(), ", ")+")")
+	optionalFields := flag.String("optional-fields", "", "optional_fields applied to every type, separated by ';'")
+	format := flag.String("format", "markdown", "output format: markdown or env")
+	out := flag.String("out", "", "output file, defaults to stdout")
+	flag.Parse()
+
+	if len(types) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	configStruct, err := buildConfigStruct(types, *optionalFields)
+	if err != nil {
+		fail(err)
+	}
+
+	var output string
+	switch *format {
+	case "markdown", "md":
+		output, err = config.GenerateMarkdown(configStruct)
+	case "env":
+		output, err = config.GenerateEnvExample(configStruct)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if *out == "" {
+		fmt.Print(output)
+		return
+	}
+	if err := os.WriteFile(*out, []byte(output), 0o644); err != nil {
+		fail(err)
+	}
+}
+
+// buildConfigStruct builds a struct value holding one field per -type flag, tagged with its prefix,
+// so the generated documentation matches what config.LoadConfig reads for the same layout.
+func buildConfigStruct(types []string, optionalFields string) (interface{}, error) {
+	fields := make([]reflect.StructField, 0, len(types))
+	for i, spec := range types {
+		prefix, name := "", spec
+		if before, after, found := strings.Cut(spec, "="); found {
+			prefix, name = before, after
+		}
+		t, ok := knownTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown config type %q, expected one of: %s", name, strings.Join(typeNames(), ", "))
+		}
+		tag := fmt.Sprintf(`prefix:"%s"`, prefix)
+		if optionalFields != "" {
+			tag = fmt.Sprintf(`%s optional_fields:"%s"`, tag, optionalFields)
+		}
+		fields = append(fields, reflect.StructField{
+			Name: fmt.Sprintf("Field%d", i),
+			Type: t,
+			Tag:  reflect.StructTag(tag),
+		})
+	}
+	return reflect.New(reflect.StructOf(fields)).Elem().Interface(), nil
+}
+
+// typeNames returns the sorted names of the known config types.
+func typeNames() []string {
+	names := make([]string, 0, len(knownTypes))
+	for name := range knownTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "configdoc:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,68 @@
+// Command migrate applies the SQL migrations of a directory to the database configured through the
+// `DB_*` variables (see config.DatabaseConfig), using db.Migrator.
+//
+// Usage:
+//
+//	go run github.com/shiroyaavish/go-common/cmd/migrate -dir ./migrations [-extensions uuid-ossp] [-dry-run] up|down [n]|status
+//
+// Services embedding their migrations should rather expose db.RunMigrationCommand from their own binary.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/db"
+)
+
+// migrateConfig is the configuration loaded from the environment.
+type migrateConfig struct {
+	Database config.DatabaseConfig `prefix:"DB" optional_fields:"LOG_LEVEL;SLOW_THRESHOLD_MS;CREATE_BATCH_SIZE;MAX_IDLE_CONNS;MAX_OPEN_CONNS;CONN_MAX_LIFETIME_SEC;CONNECT_TIMEOUT_SEC;CONNECT_RETRIES;CONNECT_RETRY_BACKOFF_MS"`
+}
+
+func main() {
+	dir := flag.String("dir", "migrations", "directory holding the <version>_<name>.<up|down>.sql files")
+	table := flag.String("table", db.DefaultMigrationsTable, "table recording the applied migrations")
+	extensions := flag.String("extensions", "", "Postgres extensions to create before migrating, separated by ','")
+	flag.Parse()
+
+	var cfg migrateConfig
+	if err := config.LoadConfig(&cfg); err != nil {
+		fail(err)
+	}
+	cfg.Database.LogLevel = "warn"
+
+	conn, err := db.ConnectDB(cfg.Database)
+	if err != nil {
+		fail(err)
+	}
+	defer conn.Disconnect()
+
+	migrator, err := db.NewMigrator(conn, os.DirFS(*dir), ".")
+	if err != nil {
+		fail(err)
+	}
+	migrator.WithTable(*table)
+	if *extensions != "" {
+		migrator.WithExtensions(strings.Split(*extensions, ",")...)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := db.RunMigrationCommand(ctx, migrator, flag.Args(), os.Stdout); err != nil {
+		_ = conn.Disconnect()
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "migrate:", err)
+	os.Exit(1)
+}
@@ -1,7 +1,9 @@
 package go_common
 
 import (
+	"context"
 	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/db"
 	"github.com/shiroyaavish/go-common/logger"
 	"os"
 	"os/signal"
@@ -47,6 +49,7 @@ import (
 type Common struct {
 	signalChannel chan os.Signal
 	isStartReady  bool
+	settings      *config.Settings
 }
 
 // UseDefault is a function that sets the default configuration by using config.UseDefaultConfig() and returns a new instance of Common.
@@ -61,7 +64,19 @@ type Common struct {
 // return &Common{}
 func UseDefault() *Common {
 	config.UseDefaultConfig()
-	return &Common{}
+	return &Common{settings: config.Default()}
+}
+
+// UseSettings returns a new instance of Common bound to the provided config.Settings instead of the package defaults.
+// Example usage:
+//
+//	settings := config.NewSettings()
+//	if err := config.NewLoader(settings).LoadConfig(&cfg); err != nil {
+//	    logger.Fatal(err)
+//	}
+//	common := UseSettings(settings)
+func UseSettings(settings *config.Settings) *Common {
+	return &Common{settings: config.OrDefault(settings)}
 }
 
 func (c *Common) WithCustomConfig() *Common {
@@ -91,12 +106,18 @@ func (c *Common) WithErrors() *Common {
 //	return &Common{}
 func UseWithConfig(commonConfig *config.CommonConfig) *Common {
 	config.SetCommonConfig(commonConfig)
-	return &Common{}
+	return &Common{settings: config.Default()}
 }
 
 func UseAWS(awsConfig *config.AWSConfig) *Common {
 	config.SetAWSConfig(awsConfig)
-	return &Common{}
+	return &Common{settings: config.Default()}
+}
+
+// Settings returns the config.Settings the Common instance is bound to, to be injected into
+// http_server.RouterConfig, aws.NewWithSettings and common.AccessLevel checks.
+func (c *Common) Settings() *config.Settings {
+	return config.OrDefault(c.settings)
 }
 
 // Init is a method of the Common struct that initializes the configuration and other dependencies.
@@ -104,6 +125,21 @@ func (c *Common) Init() {
 
 }
 
+// WithMigrations applies the pending migrations of the migrator at startup and exits the program if they fail.
+// Concurrent instances wait for each other through the migrator's advisory lock.
+// Example usage:
+//
+//	migrator, _ := db.NewMigrator(conn, migrationsFS, "migrations")
+//	UseDefault().WithMigrations(ctx, migrator).WithSignalCheck()
+func (c *Common) WithMigrations(ctx context.Context, migrator *db.Migrator) *Common {
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		logger.Fatal(err, "cannot apply migrations")
+	}
+	logger.Info("Applied %d migrations", len(applied))
+	return c
+}
+
 // WithSignalCheck is a method of the Common struct that sets up a signal handler to catch termination signals (SIGTERM, SIGKILL).
 // It creates a channel to receive the signals and notifies the channel when the specified signals are received.
 // This method runs as a goroutine to continuously listen for signals in the background.
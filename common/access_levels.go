@@ -48,21 +48,62 @@ func (a AccessLevel) ToString() string {
 // CheckAccess checks the access level and returns the necessary information based on the access level provided.
 // If the access level is AccessLevelPublic, it returns nil, nil, nil.
 // If the access level is AccessLevel
+//
+// The secrets are read from the default config.Settings, use CheckAccessWithSettings to verify against an injected instance.
 func (a AccessLevel) CheckAccess(c *fiber.Ctx) (*uuid.UUID, []string, *api_errors.Error) {
+	return a.CheckAccessWithSettings(config.Default(), c)
+}
+
+// CheckAccessWithSettings behaves like CheckAccess but verifies the API keys and JWT secrets against the provided settings.
+// A nil settings falls back to config.Default().
+func (a AccessLevel) CheckAccessWithSettings(settings *config.Settings, c *fiber.Ctx) (*uuid.UUID, []string, *api_errors.Error) {
+	return a.CheckAccessFrom(settings, HeaderFunc(func(name string) string {
+		return c.Get(name, "")
+	}))
+}
+
+// HeaderSource provides the request headers read by the access checks, so that they do not depend on the transport.
+// Header returns the value of the header name, or an empty string when it is missing.
+type HeaderSource interface {
+	Header(name string) string
+}
+
+// HeaderFunc adapts a function to a HeaderSource.
+//
+// Example usage:
+//
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	accessId, permissions, err := common.AccessLevelUser.CheckAccessFrom(settings, common.HeaderFunc(func(name string) string {
+//	    if values := md.Get(name); len(values) > 0 {
+//	        return values[0]
+//	    }
+//	    return ""
+//	}))
+type HeaderFunc func(name string) string
+
+// Header implements HeaderSource.
+func (f HeaderFunc) Header(name string) string {
+	return f(name)
+}
+
+// CheckAccessFrom behaves like CheckAccessWithSettings but reads the Api-Key, X-Api-Key and Authorization headers from headers.
+// A nil settings falls back to config.Default().
+func (a AccessLevel) CheckAccessFrom(settings *config.Settings, headers HeaderSource) (*uuid.UUID, []string, *api_errors.Error) {
+	settings = config.OrDefault(settings)
 	// Checks access according to the values
 	switch a {
 	case AccessLevelPublic:
 		return nil, nil, nil
 	case AccessLevelService:
-		return checkServiceAccess(c.Get("Api-Key", ""))
+		return checkServiceAccess(settings, headers.Header("Api-Key"))
 	case AccessLevelSearch:
-		_, _, err := checkServiceAccess(c.Get("X-Api-Key", ""))
+		_, _, err := checkServiceAccess(settings, headers.Header("X-Api-Key"))
 		if err == nil {
 			return nil, nil, nil
 		}
 		fallthrough
 	case AccessLevelUser, AccessLevelAdmin:
-		authHeader := c.Get("Authorization", "")
+		authHeader := headers.Header("Authorization")
 		if authHeader == "" {
 			return nil, nil, api_errors.ErrUnauthorized
 		}
@@ -71,7 +112,7 @@ func (a AccessLevel) CheckAccess(c *fiber.Ctx) (*uuid.UUID, []string, *api_error
 		if len(authHeaderParts) != 2 || strings.ToLower(authHeaderParts[0]) != "bearer" {
 			return nil, nil, api_errors.ErrUnauthorized
 		}
-		return checkUserAndAdminAccess(authHeaderParts[1], a)
+		return checkUserAndAdminAccess(settings, authHeaderParts[1], a)
 	default:
 		return nil, nil, api_errors.ErrUnauthorized
 	}
@@ -79,8 +120,8 @@ func (a AccessLevel) CheckAccess(c *fiber.Ctx) (*uuid.UUID, []string, *api_error
 
 // checkUserAndAdminAccess checks the user and admin access based on the provided token and access level.
 // It parses the JWT token and verifies the access level.
-// If the access level is AccessLevelAdmin, it uses the admin secret from the config.
-// If the access level is not AccessLevelAdmin, it uses the user secret from the config.
+// If the access level is AccessLevelAdmin, it uses the admin secret from the settings.
+// If the access level is not AccessLevelAdmin, it uses the user secret from the settings.
 // It validates the token claims and checks for the access level.
 // If the access level is AccessLevelSearch, it checks if the "search" access is allowed.
 // If the access level is not allowed, it returns an unauthorized error.
@@ -88,16 +129,16 @@ func (a AccessLevel) CheckAccess(c *fiber.Ctx) (*uuid.UUID, []string, *api_error
 // It retrieves the permissions from the token claims and converts them into a string slice.
 // It returns the access ID, access permissions, and nil error if successful.
 // If any error occurs during parsing, validation, or retrieving claims, it returns nil access ID, nil access permissions, and an unauthorized error.
-func checkUserAndAdminAccess(tokenStr string, accessLevel AccessLevel) (*uuid.UUID, []string, *api_errors.Error) {
+func checkUserAndAdminAccess(settings *config.Settings, tokenStr string, accessLevel AccessLevel) (*uuid.UUID, []string, *api_errors.Error) {
 	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, api_errors.ErrUnauthorized
 		}
 
 		if accessLevel == AccessLevelAdmin {
-			return []byte(config.GetAdminSecret()), nil
+			return []byte(settings.GetAdminSecret()), nil
 		}
-		return []byte(config.GetUserSecret()), nil
+		return []byte(settings.GetUserSecret()), nil
 	})
 	if err != nil {
 		return nil, nil, api_errors.ErrUnauthorized
@@ -138,10 +179,10 @@ func checkUserAndAdminAccess(tokenStr string, accessLevel AccessLevel) (*uuid.UU
 }
 
 // checkServiceAccess checks the service access based on the provided API key.
-// If the API key matches the service secret from the config, it returns nil access ID, nil access permissions, and nil error.
+// If the API key matches the service secret from the settings, it returns nil access ID, nil access permissions, and nil error.
 // If the API key does not match the service secret, it returns nil access ID, nil access permissions, and an unauthorized error.
-func checkServiceAccess(apiKey string) (*uuid.UUID, []string, *api_errors.Error) {
-	if apiKey == config.GetServiceSecret() {
+func checkServiceAccess(settings *config.Settings, apiKey string) (*uuid.UUID, []string, *api_errors.Error) {
+	if apiKey == settings.GetServiceSecret() {
 		return nil, nil, nil
 	}
 	return nil, nil, api_errors.ErrUnauthorized
@@ -0,0 +1,97 @@
+package common
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// CorrelationIdHeader is the header (gRPC metadata key in lower case) carrying the correlation ID of a request across services.
+const CorrelationIdHeader = "X-Correlation-ID"
+
+// accessIdCtxKey is the context key holding the access ID of the caller.
+type accessIdCtxKey struct{}
+
+// accessPermissionsCtxKey is the context key holding the permissions of the caller.
+type accessPermissionsCtxKey struct{}
+
+// correlationIdCtxKey is the context key holding the correlation ID of the request.
+type correlationIdCtxKey struct{}
+
+// projectCtxKey is the context key holding the project (tenant) of the caller.
+type projectCtxKey struct{}
+
+// WithAccessId returns a context carrying the access ID of the caller, nil IDs are ignored.
+// http_server.RequestHandlerBuilder sets it on the user context of every request.
+func WithAccessId(ctx context.Context, accessId *uuid.UUID) context.Context {
+	if accessId == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, accessIdCtxKey{}, *accessId)
+}
+
+// AccessIdFromContext returns the access ID carried by ctx.
+func AccessIdFromContext(ctx context.Context) (*uuid.UUID, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	accessId, ok := ctx.Value(accessIdCtxKey{}).(uuid.UUID)
+	if !ok {
+		return nil, false
+	}
+	return &accessId, true
+}
+
+// WithAccessPermissions returns a context carrying the permissions of the caller, nil permissions are ignored.
+// http_server.RequestHandlerBuilder sets it on the user context of every request.
+func WithAccessPermissions(ctx context.Context, permissions []string) context.Context {
+	if permissions == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, accessPermissionsCtxKey{}, permissions)
+}
+
+// AccessPermissionsFromContext returns the permissions carried by ctx.
+func AccessPermissionsFromContext(ctx context.Context) ([]string, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	permissions, ok := ctx.Value(accessPermissionsCtxKey{}).([]string)
+	return permissions, ok
+}
+
+// WithProject returns a context carrying the project of the caller, UnknownProject is ignored.
+// http_server.RequestHandlerBuilder sets it on the user context of every request.
+func WithProject(ctx context.Context, project Project) context.Context {
+	if project == UnknownProject {
+		return ctx
+	}
+	return context.WithValue(ctx, projectCtxKey{}, project)
+}
+
+// ProjectFromContext returns the project carried by ctx.
+func ProjectFromContext(ctx context.Context) (Project, bool) {
+	if ctx == nil {
+		return UnknownProject, false
+	}
+	project, ok := ctx.Value(projectCtxKey{}).(Project)
+	return project, ok
+}
+
+// WithCorrelationId returns a context carrying the correlation ID of the request, empty IDs are ignored.
+// http_server.RequestHandlerBuilder sets it on the user context of every request from the X-Correlation-ID header.
+func WithCorrelationId(ctx context.Context, correlationId string) context.Context {
+	if correlationId == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIdCtxKey{}, correlationId)
+}
+
+// CorrelationIdFromContext returns the correlation ID carried by ctx.
+func CorrelationIdFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	correlationId, ok := ctx.Value(correlationIdCtxKey{}).(string)
+	return correlationId, ok
+}
@@ -20,6 +20,8 @@ var alpha = regexp.MustCompile("[^a-zA-Z0-9 ]+")
 //
 // Returns:
 // - The transformed query string.
+//
+// See the db/search package for prefix, phrase and negation support and the matching gorm scopes.
 func ToTsQuery(query string) string {
 	if len(query) == 0 {
 		return query
@@ -3,35 +3,33 @@ package config
 // CommonConfig represents the configuration
 type CommonConfig struct{}
 
-var commonConfig CommonConfig
-
 // UseDefaultConfig sets the default values for the common configuration.
 func UseDefaultConfig() {
-	commonConfig = CommonConfig{}
+	defaultSettings.CommonConfig = CommonConfig{}
 
 }
 
-// GetCommonConfig returns a pointer to the `commonConfig` variable.
+// GetCommonConfig returns a pointer to the common configuration of the default settings.
 func GetCommonConfig() *CommonConfig {
-	return &commonConfig
+	return defaultSettings.GetCommonConfig()
 }
 
-// SetCommonConfig sets the values of the commonConfig variable based on the provided config parameter.
+// SetCommonConfig sets the values of the default common configuration based on the provided config parameter.
 // It assigns the following values to the commonConfig variable:
 func SetCommonConfig(config *CommonConfig) {
-	commonConfig = *config
+	defaultSettings.SetCommonConfig(config)
 }
 
 // AWSConfig represents the configuration for AWS service
 type AWSConfig struct {
-	SecretAccessKey string `json:"secret_access_key" config:"SECRET_ACCESS_KEY"`
-	AccessKeyID     string `json:"access_key_id" config:"ACCESS_KEY_ID"`
-	Region          string `json:"region" config:"REGION"`
+	SecretAccessKey string `json:"secret_access_key" config:"SECRET_ACCESS_KEY" desc:"AWS secret access key"`
+	AccessKeyID     string `json:"access_key_id" config:"ACCESS_KEY_ID" desc:"AWS access key ID"`
+	Region          string `json:"region" config:"REGION" desc:"AWS region"`
 }
 
 // GetAWSConfig returns a pointer to the default AWSConfig object.
 // AWSConfig is a struct that contains the SecretAccessKey, AccessKeyID, and Region.
-// It is accessed from the defaultSettings package variable.
+// It is accessed from the default Settings instance, use Settings.GetAWSConfig for an injected instance.
 // Example usage:
 //
 //	cfg := &aws.Config{
@@ -47,7 +45,7 @@ type AWSConfig struct {
 //	a.sess = sess
 //	return a
 func GetAWSConfig() *AWSConfig {
-	return &defaultSettings.AWSConfig
+	return defaultSettings.GetAWSConfig()
 }
 
 // SetAWSConfig sets the AWS configuration by replacing the default settings with the provided configuration.
@@ -56,5 +54,5 @@ func GetAWSConfig() *AWSConfig {
 // - `AccessKeyID`: The access key ID for AWS authentication.
 // - `Region`: The AWS region.
 func SetAWSConfig(config *AWSConfig) {
-	defaultSettings.AWSConfig = *config
+	defaultSettings.SetAWSConfig(config)
 }
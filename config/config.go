@@ -17,24 +17,103 @@ const (
 // DefaultSettings represents the default settings for the software.
 // It contains the environment and AWS configuration.
 type DefaultSettings struct {
-	Environment   `config:"ENVIRONMENT"`
+	Environment   `config:"ENVIRONMENT" desc:"Environment of the service: uat, stg, prod or anything else for development"`
 	AWSConfig     `prefix:"AWS"`
-	UserSecret    string `json:"user_secret" config:"USER_SECRET"`
-	AdminSecret   string `json:"admin_secret" config:"ADMIN_SECRET"`
-	ServiceSecret string `json:"service_secret" config:"SERVICE_SECRET"`
+	UserSecret    string `json:"user_secret" config:"USER_SECRET" desc:"HMAC secret of user JWTs"`
+	AdminSecret   string `json:"admin_secret" config:"ADMIN_SECRET" desc:"HMAC secret of admin JWTs"`
+	ServiceSecret string `json:"service_secret" config:"SERVICE_SECRET" desc:"API key of service to service calls"`
+}
+
+// Settings is a self-contained instance of the loaded configuration.
+// It holds the DefaultSettings (environment, AWS credentials and secrets) together with the CommonConfig,
+// so it can be constructed per test or per tenant and injected wherever configuration is needed
+// instead of reading the package level defaults.
+//
+// Example usage:
+//
+//	settings := config.NewSettings()
+//	loader := config.NewLoader(settings)
+//	if err := loader.LoadConfig(&cfg); err != nil {
+//	    return err
+//	}
+//	router := http_server.NewRouter(http_server.RouterConfig{Settings: settings})
+type Settings struct {
+	DefaultSettings
+	CommonConfig CommonConfig
+}
+
+// defaultSettings is the Settings instance used by the package level functions.
+var defaultSettings = NewSettings()
+
+// NewSettings returns an empty Settings instance running in the Development environment.
+func NewSettings() *Settings {
+	return &Settings{}
+}
+
+// Default returns the package level Settings instance that backs LoadConfig and the Get/Set helpers.
+func Default() *Settings {
+	return defaultSettings
+}
+
+// OrDefault returns s when it is not nil, otherwise the package level Settings instance.
+func OrDefault(s *Settings) *Settings {
+	if s != nil {
+		return s
+	}
+	return defaultSettings
+}
+
+// GetCurrentEnvironment returns the environment of the settings.
+func (s *Settings) GetCurrentEnvironment() Environment {
+	return s.Environment
+}
+
+// GetAdminSecret returns the admin secret
+func (s *Settings) GetAdminSecret() string {
+	return s.AdminSecret
+}
+
+// GetUserSecret returns the user Secret
+func (s *Settings) GetUserSecret() string {
+	return s.UserSecret
+}
+
+// GetServiceSecret returns the service secret
+func (s *Settings) GetServiceSecret() string {
+	return s.ServiceSecret
+}
+
+// GetAWSConfig returns a pointer to the AWSConfig of the settings.
+func (s *Settings) GetAWSConfig() *AWSConfig {
+	return &s.AWSConfig
+}
+
+// SetAWSConfig replaces the AWSConfig of the settings with the provided configuration.
+func (s *Settings) SetAWSConfig(config *AWSConfig) {
+	s.AWSConfig = *config
+}
+
+// GetCommonConfig returns a pointer to the CommonConfig of the settings.
+func (s *Settings) GetCommonConfig() *CommonConfig {
+	return &s.CommonConfig
+}
+
+// SetCommonConfig replaces the CommonConfig of the settings with the provided configuration.
+func (s *Settings) SetCommonConfig(config *CommonConfig) {
+	s.CommonConfig = *config
 }
 
 // GetAdminSecret returns the admin secret
 func GetAdminSecret() string {
-	return defaultSettings.AdminSecret
+	return defaultSettings.GetAdminSecret()
 }
 
 // GetUserSecret returns the user Secret
 func GetUserSecret() string {
-	return defaultSettings.UserSecret
+	return defaultSettings.GetUserSecret()
 }
 
 // GetServiceSecret returns the service secret
 func GetServiceSecret() string {
-	return defaultSettings.ServiceSecret
+	return defaultSettings.GetServiceSecret()
 }
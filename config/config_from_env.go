@@ -11,80 +11,186 @@ import (
 	"github.com/spf13/viper"
 )
 
-var defaultSettings DefaultSettings
+// Loader loads configuration structs from the environment and a `.env` file into a Settings instance.
+// Every Loader owns its own viper instance, so several loaders can run side by side (parallel tests,
+// multi-tenant processes) without sharing state. The package level LoadConfig uses a default Loader
+// backed by the global viper instance and the Default Settings.
+//
+// Example usage:
+//
+//	loader := config.NewLoader(nil).WithConfigPath("./tenants/acme")
+//	if err := loader.LoadConfig(&cfg); err != nil {
+//	    return err
+//	}
+//	settings := loader.Settings()
+type Loader struct {
+	v           *viper.Viper
+	settings    *Settings
+	configPaths []string
+	pathsAdded  int
+	imported    bool
+}
+
+// defaultLoader is the Loader used by the package level LoadConfig.
+var defaultLoader = &Loader{v: viper.GetViper(), settings: defaultSettings}
+
+// NewLoader creates a new Loader with its own viper instance that populates the given settings.
+// If settings is nil a new Settings instance is created.
+func NewLoader(settings *Settings) *Loader {
+	if settings == nil {
+		settings = NewSettings()
+	}
+	return &Loader{
+		v:        viper.New(),
+		settings: settings,
+	}
+}
+
+// WithConfigPath adds a directory in which the `.env` file is searched, defaults to the current directory.
+func (l *Loader) WithConfigPath(path string) *Loader {
+	l.configPaths = append(l.configPaths, path)
+	return l
+}
+
+// Settings returns the Settings instance populated by the loader.
+func (l *Loader) Settings() *Settings {
+	return l.settings
+}
+
+// Viper returns the viper instance used by the loader.
+func (l *Loader) Viper() *viper.Viper {
+	return l.v
+}
+
+// Get returns the raw value of the given environment variable (or `.env` entry) as seen by the loader.
+// It is meant for values that are not part of a config struct, such as feature flags.
+// The `.env` file is read on the first call, use Reload to read it again.
+func (l *Loader) Get(envName string) string {
+	if !l.imported {
+		l.importEnv()
+	}
+	return l.v.GetString(envName)
+}
+
+// Reload reads the `.env` file again, the values returned by Get reflect its current content.
+func (l *Loader) Reload() {
+	l.importEnv()
+}
+
+// DefaultLoader returns the Loader used by the package level LoadConfig.
+func DefaultLoader() *Loader {
+	return defaultLoader
+}
 
 // GetCurrentEnvironment returns the current environment based on the default settings.
 func GetCurrentEnvironment() Environment {
-	return defaultSettings.Environment
+	return defaultSettings.GetCurrentEnvironment()
 }
 
-// LoadConfig loads the configuration for the given configStruct by setting appropriate values based on the current environment.
-// It imports environment variables from a `.env` file in the current directory and checks if the environment variable "ENVIRONMENT" matches any of the environment keys (UAT, Staging
+// LoadConfig loads the configuration for the given configStruct into the default Settings.
+// See Loader.LoadConfig for details.
 func LoadConfig(configStruct interface{}) error {
-	importEnv()
-	val := viper.GetString("ENVIRONMENT")
+	return defaultLoader.LoadConfig(configStruct)
+}
+
+// LoadConfig loads the configuration for the given configStruct by setting appropriate values based on the current environment.
+// It imports environment variables from a `.env` file in the configured directories and checks if the environment variable "ENVIRONMENT" matches any of the environment keys (UAT, Staging
+func (l *Loader) LoadConfig(configStruct interface{}) error {
+	l.importEnv()
+	val := l.v.GetString("ENVIRONMENT")
 	val = strings.ToLower(val)
 	switch val {
 	case "uat":
-		defaultSettings.Environment = UAT
+		l.settings.Environment = UAT
 	case "stg":
-		defaultSettings.Environment = Staging
+		l.settings.Environment = Staging
 	case "prod":
-		defaultSettings.Environment = Production
+		l.settings.Environment = Production
 	default:
-		defaultSettings.Environment = Development
+		l.settings.Environment = Development
 	}
-	return loadConfigRecursive(reflect.ValueOf(configStruct).Elem(), "", false)
+	return l.loadConfigRecursive(reflect.ValueOf(configStruct).Elem(), "", false)
 }
 
-// loadConfigRecursive traverses a struct recursively and loads configuration values from environment variables or default values.
-// It takes in the structValue to be populated, prefix for potential environment variable prefixes, and optionalFields to specify which fields are optional.
-// It returns an error if any required fields are missing.
-func loadConfigRecursive(structValue reflect.Value, prefix string, _ bool, optionalFields ...string) error {
+// configField is a single leaf field of a config struct as resolved by walkConfigFields.
+type configField struct {
+	Field        reflect.StructField
+	Value        reflect.Value
+	Path         string
+	EnvName      string
+	DefaultValue string
+	IsOptional   bool
+}
+
+// walkConfigFields traverses a struct recursively and calls visit for every field carrying a `config` tag.
+// Nested structs replace the prefix with their own `prefix` tag and mark the fields listed in their
+// `optional_fields` tag (separated by ";") as optional. The environment variable name of a field is
+// `<prefix>_<config>`, or just `<config>` without a prefix.
+// The walk stops at the first error returned by visit.
+func walkConfigFields(structValue reflect.Value, prefix, path string, optionalFields []string, visit func(f configField) error) error {
 	structType := structValue.Type()
 
 	for i := 0; i < structType.NumField(); i++ {
 		field := structType.Field(i)
 		fieldValue := structValue.Field(i)
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = fmt.Sprintf("%s.%s", path, field.Name)
+		}
 
 		if field.Type.Kind() == reflect.Struct {
 			prefix := field.Tag.Get("prefix")
-			isOptional := field.Tag.Get("optional") == "true"
 			optionalFieldsStr := field.Tag.Get("optional_fields")
 			optionalFields := make([]string, 0)
 			if optionalFieldsStr != "" {
 				optionalFields = strings.Split(optionalFieldsStr, ";")
 			}
-			if err := loadConfigRecursive(fieldValue, prefix, isOptional, optionalFields...); err != nil {
+			if err := walkConfigFields(fieldValue, prefix, fieldPath, optionalFields, visit); err != nil {
 				return err
 			}
 		}
 
 		envName := field.Tag.Get("config")
-		defaultValue := field.Tag.Get("default")
-		isOptional := field.Tag.Get("optional") == "true" || utils.IsInArray(envName, optionalFields)
-
 		if envName == "" {
 			continue
-		} else if prefix != "" {
+		}
+
+		isOptional := field.Tag.Get("optional") == "true" || utils.IsInArray(envName, optionalFields)
+		if prefix != "" {
 			envName = fmt.Sprintf("%s_%s", prefix, envName)
 		}
 
-		if value := viper.GetString(envName); value != "" {
-			setFieldValue(envName, fieldValue, value, field.Type.Kind(), false)
-		} else if defaultValue != "" {
-			setFieldValue(envName, fieldValue, defaultValue, field.Type.Kind(), true)
-		} else {
-			if isOptional {
-				continue
-			}
-			return fmt.Errorf("missing value for %s", field.Name)
+		if err := visit(configField{
+			Field:        field,
+			Value:        fieldValue,
+			Path:         fieldPath,
+			EnvName:      envName,
+			DefaultValue: field.Tag.Get("default"),
+			IsOptional:   isOptional,
+		}); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// loadConfigRecursive traverses a struct recursively and loads configuration values from environment variables or default values.
+// It takes in the structValue to be populated, prefix for potential environment variable prefixes, and optionalFields to specify which fields are optional.
+// It returns an error if any required fields are missing.
+func (l *Loader) loadConfigRecursive(structValue reflect.Value, prefix string, _ bool, optionalFields ...string) error {
+	return walkConfigFields(structValue, prefix, "", optionalFields, func(f configField) error {
+		if value := l.v.GetString(f.EnvName); value != "" {
+			l.setFieldValue(f.EnvName, f.Value, value, f.Field.Type.Kind(), false)
+		} else if f.DefaultValue != "" {
+			l.setFieldValue(f.EnvName, f.Value, f.DefaultValue, f.Field.Type.Kind(), true)
+		} else if !f.IsOptional {
+			return fmt.Errorf("missing value for %s", f.Field.Name)
+		}
+		return nil
+	})
+}
+
 // setFieldValue sets the value of a field in a struct based on the provided parameters.
 //
 // Parameters:
@@ -104,23 +210,23 @@ func loadConfigRecursive(structValue reflect.Value, prefix string, _ bool, optio
 //
 //	fieldType := reflect.TypeOf(MyStruct{}.MyField)
 //	fieldValue := reflect.New(fieldType).Elem()
-//	setFieldValue("ENV_VAR_NAME", fieldValue, "default value", fieldType.Kind(), true)
+//	l.setFieldValue("ENV_VAR_NAME", fieldValue, "default value", fieldType.Kind(), true)
 //
 // For additional information, refer to the following helper functions:
 // - parseInteger: to parse integer values
 // - parseBool: to parse boolean values
 // - utils.IsInArray: to check if a value is present in an array.
-func setFieldValue(envName string, fieldValue reflect.Value, value string, kind reflect.Kind, parseDefault bool) {
+func (l *Loader) setFieldValue(envName string, fieldValue reflect.Value, value string, kind reflect.Kind, parseDefault bool) {
 	switch kind {
 	case reflect.String:
 		if !parseDefault {
-			fieldValue.SetString(viper.GetString(envName))
+			fieldValue.SetString(l.v.GetString(envName))
 		} else {
 			fieldValue.SetString(value)
 		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if !parseDefault {
-			fieldValue.SetInt(viper.GetInt64(envName))
+			fieldValue.SetInt(l.v.GetInt64(envName))
 		} else {
 			if intValue, err := parseInteger(value, fieldValue.Type().Bits()); err == nil {
 				fieldValue.SetInt(intValue)
@@ -128,7 +234,7 @@ func setFieldValue(envName string, fieldValue reflect.Value, value string, kind
 		}
 	case reflect.Bool:
 		if !parseDefault {
-			fieldValue.SetBool(viper.GetBool(envName))
+			fieldValue.SetBool(l.v.GetBool(envName))
 		} else {
 			if boolValue, err := parseBool(value); err == nil {
 				fieldValue.SetBool(boolValue)
@@ -150,22 +256,31 @@ func parseBool(value string) (bool, error) {
 }
 
 // importEnv sets up the configuration for reading environment variables.
-// It configures viper to look for a config file named ".env" in the current directory.
-// It then adds the current directory as a config path and sets the config type to "env".
+// It configures the loader's viper instance to look for a config file named ".env" in the configured directories.
+// It adds the current directory as a config path when none was configured and sets the config type to "env",
+// every config path is added to viper once.
 // The function also checks if environment variables match any of the existing keys and loads them using viper.AutomaticEnv().
 // If there is an error reading the config file, it checks if the error is of type viper.ConfigFileNotFoundError and ignores it.
 // Usage example:
 //
-//	importEnv()
-func importEnv() {
-	viper.SetConfigName(".env")
-	viper.SetConfigType("env")
-	viper.AddConfigPath(".")
+//	l.importEnv()
+func (l *Loader) importEnv() {
+	l.v.SetConfigName(".env")
+	l.v.SetConfigType("env")
+	if len(l.configPaths) == 0 {
+		l.configPaths = append(l.configPaths, ".")
+	}
+	// Only the paths added since the last import are passed to viper
+	for _, path := range l.configPaths[l.pathsAdded:] {
+		l.v.AddConfigPath(path)
+	}
+	l.pathsAdded = len(l.configPaths)
+	l.imported = true
 
 	// checks if environment variables match any of the existing keys and loads them.
-	viper.AutomaticEnv()
+	l.v.AutomaticEnv()
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := l.v.ReadInConfig(); err != nil {
 		var configFileNotFoundError viper.ConfigFileNotFoundError
 		if errors.As(err, &configFileNotFoundError) {
 			// Config file not found ignoring error
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// VariableDoc describes a single environment variable read by LoadConfig.
+// It contains the following fields:
+// - Name: the fully prefixed environment variable name
+// - Field: the Go path of the field inside the config struct
+// - Type: the kind of the field (string, int, bool, ...)
+// - Default: the value of the `default` tag
+// - Optional: whether the loader accepts a missing value, either from the `optional` tag or the parent's `optional_fields`
+// - Description: the value of the `desc` tag
+type VariableDoc struct {
+	Name        string `json:"name"`
+	Field       string `json:"field"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Optional    bool   `json:"optional"`
+	Description string `json:"description,omitempty"`
+}
+
+// DescribeConfig walks the given config struct (or pointer to one) exactly as LoadConfig does and
+// returns the documentation of every environment variable it reads, in declaration order.
+//
+// Example usage:
+//
+//	type Config struct {
+//	    Database config.DatabaseConfig `prefix:"DB" optional_fields:"SSL_MODE"`
+//	    Debug    bool                  `config:"DEBUG" default:"false" desc:"Enables debug logging"`
+//	}
+//	docs, err := config.DescribeConfig(Config{})
+func DescribeConfig(configStruct interface{}) ([]VariableDoc, error) {
+	structType := reflect.TypeOf(configStruct)
+	if structType == nil {
+		return nil, fmt.Errorf("config struct is nil")
+	}
+	for structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a struct, got %s", structType.Kind())
+	}
+
+	docs := make([]VariableDoc, 0)
+	err := walkConfigFields(reflect.New(structType).Elem(), "", "", nil, func(f configField) error {
+		docs = append(docs, VariableDoc{
+			Name:        f.EnvName,
+			Field:       f.Path,
+			Type:        f.Field.Type.Kind().String(),
+			Default:     f.DefaultValue,
+			Optional:    f.IsOptional || f.DefaultValue != "",
+			Description: f.Field.Tag.Get("desc"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// WriteMarkdown writes the given variables as a Markdown table to w.
+func WriteMarkdown(w io.Writer, docs []VariableDoc) error {
+	var builder strings.Builder
+	builder.WriteString("| Variable | Type | Default | Optional | Description |\n")
+	builder.WriteString("|----------|------|---------|----------|-------------|\n")
+	for _, doc := range docs {
+		optional := "no"
+		if doc.Optional {
+			optional = "yes"
+		}
+		builder.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s | %s |\n",
+			doc.Name, doc.Type, markdownCode(doc.Default), optional, markdownEscape(doc.Description)))
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// WriteEnvExample writes the given variables as a `.env.example` file to w.
+// Every variable is preceded by a comment holding its description, type and whether it is required,
+// and is assigned its default value (or left empty).
+func WriteEnvExample(w io.Writer, docs []VariableDoc) error {
+	var builder strings.Builder
+	for i, doc := range docs {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		if doc.Description != "" {
+			builder.WriteString(fmt.Sprintf("# %s\n", doc.Description))
+		}
+		required := "required"
+		if doc.Optional {
+			required = "optional"
+		}
+		builder.WriteString(fmt.Sprintf("# %s, %s\n", doc.Type, required))
+		builder.WriteString(fmt.Sprintf("%s=%s\n", doc.Name, doc.Default))
+	}
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// GenerateMarkdown returns the Markdown documentation table of the given config struct.
+func GenerateMarkdown(configStruct interface{}) (string, error) {
+	docs, err := DescribeConfig(configStruct)
+	if err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	if err := WriteMarkdown(&builder, docs); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// GenerateEnvExample returns the `.env.example` template of the given config struct.
+func GenerateEnvExample(configStruct interface{}) (string, error) {
+	docs, err := DescribeConfig(configStruct)
+	if err != nil {
+		return "", err
+	}
+	var builder strings.Builder
+	if err := WriteEnvExample(&builder, docs); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// markdownCode wraps a non-empty value in backticks.
+func markdownCode(value string) string {
+	if value == "" {
+		return ""
+	}
+	return fmt.Sprintf("`%s`", value)
+}
+
+// markdownEscape escapes the characters that would break a Markdown table cell.
+func markdownEscape(value string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(value)
+}
@@ -8,20 +8,48 @@ package config
 // - Password: the password for authenticating to the database
 // - DBName: the name of the database to connect to
 // - SSLMode: the SSL mode for the database connection
+// - LogLevel: the gorm log level (silent, error, warn or info)
+// - SlowThresholdMs: queries slower than this are logged as slow queries
+// - CreateBatchSize: the gorm batch size used when creating slices
+// - MaxIdleConns, MaxOpenConns, ConnMaxLifetimeSec: the connection pool settings
+// - ConnectTimeoutSec: the timeout of a single connection attempt
+// - StatementTimeoutMs: the Postgres statement_timeout of every session, 0 disables it
+// - ApplicationName: the Postgres application_name reported in pg_stat_activity
+// - SearchPath: the Postgres search_path of every session
+// - ConnectRetries, ConnectRetryBackoffMs: the retries at startup, the backoff doubles after every attempt
+//
+// Zero values of the tuning fields fall back to the defaults listed in the `default` tags,
+// except ConnectRetries where 0 means a single connection attempt.
 type DatabaseConfig struct {
-	Host     string `json:"host" validate:"required" config:"HOST"`
-	Port     string `json:"port" validate:"required" config:"PORT"`
-	User     string `json:"user" validate:"required" config:"USER"`
-	Password string `json:"password" validate:"required" config:"PASSWORD"`
-	DBName   string `json:"db_name" validate:"required" config:"NAME"`
-	SSLMode  string `json:"ssl_mode" validate:"required" config:"SSL_MODE"`
+	Host     string `json:"host" validate:"required" config:"HOST" desc:"Hostname or IP address of the database server"`
+	Port     string `json:"port" validate:"required" config:"PORT" desc:"Port of the database server"`
+	User     string `json:"user" validate:"required" config:"USER" desc:"Username used to authenticate to the database"`
+	Password string `json:"password" validate:"required" config:"PASSWORD" desc:"Password used to authenticate to the database"`
+	DBName   string `json:"db_name" validate:"required" config:"NAME" desc:"Name of the database to connect to"`
+	SSLMode  string `json:"ssl_mode" validate:"required" config:"SSL_MODE" desc:"SSL mode of the database connection"`
+
+	LogLevel        string `json:"log_level" config:"LOG_LEVEL" default:"info" desc:"gorm log level: silent, error, warn or info"`
+	SlowThresholdMs int    `json:"slow_threshold_ms" config:"SLOW_THRESHOLD_MS" default:"1000" desc:"Queries slower than this are logged as slow queries"`
+	CreateBatchSize int    `json:"create_batch_size" config:"CREATE_BATCH_SIZE" default:"10" desc:"gorm batch size used when creating slices"`
+
+	MaxIdleConns       int `json:"max_idle_conns" config:"MAX_IDLE_CONNS" default:"10" desc:"Maximum number of idle connections in the pool"`
+	MaxOpenConns       int `json:"max_open_conns" config:"MAX_OPEN_CONNS" default:"100" desc:"Maximum number of open connections"`
+	ConnMaxLifetimeSec int `json:"conn_max_lifetime_sec" config:"CONN_MAX_LIFETIME_SEC" default:"3600" desc:"Maximum lifetime of a connection in seconds"`
+
+	ConnectTimeoutSec  int    `json:"connect_timeout_sec" config:"CONNECT_TIMEOUT_SEC" default:"10" desc:"Timeout of a single connection attempt in seconds"`
+	StatementTimeoutMs int    `json:"statement_timeout_ms" config:"STATEMENT_TIMEOUT_MS" optional:"true" desc:"Postgres statement_timeout in milliseconds, 0 disables it"`
+	ApplicationName    string `json:"application_name" config:"APPLICATION_NAME" optional:"true" desc:"Postgres application_name reported in pg_stat_activity"`
+	SearchPath         string `json:"search_path" config:"SEARCH_PATH" optional:"true" desc:"Postgres search_path of every session"`
+
+	ConnectRetries        int `json:"connect_retries" config:"CONNECT_RETRIES" default:"5" desc:"Number of connection retries at startup"`
+	ConnectRetryBackoffMs int `json:"connect_retry_backoff_ms" config:"CONNECT_RETRY_BACKOFF_MS" default:"500" desc:"Initial backoff between connection retries in milliseconds, doubled after every attempt"`
 }
 
 // GRPCConfig represents the configuration for a GRPC server.
 // It contains the following field:
 // - Port: the port number on which the GRPC server will listen for incoming connections.
 type GRPCConfig struct {
-	Port int `json:"port" validate:"required" config:"PORT"`
+	Port int `json:"port" validate:"required" config:"PORT" desc:"Port the gRPC server listens on"`
 }
 
 // RedisConfig represents the configuration for connecting to a Redis server.
@@ -30,41 +58,41 @@ type GRPCConfig struct {
 // - Password: the password for authenticating to the Redis server
 // - DBNumber: the database number to use for the Redis connection
 type RedisConfig struct {
-	Host     string `json:"host" validate:"required" config:"HOST"`
-	Password string `json:"password" validate:"required" config:"PASSWORD"`
-	DBNumber int    `json:"db_number" validate:"required" config:"DB_NUMBER"`
+	Host     string `json:"host" validate:"required" config:"HOST" desc:"Address (host:port) of the Redis server"`
+	Password string `json:"password" validate:"required" config:"PASSWORD" desc:"Password used to authenticate to Redis"`
+	DBNumber int    `json:"db_number" validate:"required" config:"DB_NUMBER" desc:"Redis database number"`
 }
 
 // StripeConfig represents the configuration for interacting with the Stripe API.
 // It contains the following field:
 // - SecretKey: the secret key for authenticating with the Stripe API
 type StripeConfig struct {
-	SecretKey string `json:"secret_key" config:"SECRET_KEY" validate:"required"`
+	SecretKey string `json:"secret_key" config:"SECRET_KEY" validate:"required" desc:"Stripe API secret key"`
 }
 
-// WrapperConfig contains the config for a GRPC wrapper
+// WrapperConfig contains the config for a GRPC wrapper, grpc/client.New dials it.
 type WrapperConfig struct {
-	TimeoutSec int    `config:"TIMEOUT_SEC"`
-	GrpcUrl    string `config:"GRPC_URL"`
+	TimeoutSec int    `config:"TIMEOUT_SEC" desc:"Timeout in seconds of calls to the wrapped service"`
+	GrpcUrl    string `config:"GRPC_URL" desc:"gRPC address of the wrapped service"`
 }
 
 // ServerConfig is to run fiber.App, all the other config variables required will be present in data.
 type ServerConfig struct {
-	Port        string `json:"port" validate:"required" config:"PORT"`
-	Version     string `json:"version" validate:"required" config:"VERSION"`
-	ProjectName string `json:"project_name" validate:"required" config:"PROJECT_NAME"`
+	Port        string `json:"port" validate:"required" config:"PORT" desc:"Port the HTTP server listens on"`
+	Version     string `json:"version" validate:"required" config:"VERSION" desc:"Version of the service"`
+	ProjectName string `json:"project_name" validate:"required" config:"PROJECT_NAME" desc:"Name of the project"`
 }
 
 // CashFreeConfig is the overall configuration for the application
 type CashFreeConfig struct {
-	APPId  string `json:"app_id" validate:"required" config:"APP_ID"`
-	APIKey string `json:"api_key" validate:"required" config:"API_KEY"`
+	APPId  string `json:"app_id" validate:"required" config:"APP_ID" desc:"CashFree application ID"`
+	APIKey string `json:"api_key" validate:"required" config:"API_KEY" desc:"CashFree API key"`
 }
 
 type RabbitMQConfig struct {
-	URL      string `json:"url" validate:"required" config:"URL"`
-	Username string `json:"username" validate:"required" config:"USERNAME"`
-	Password string `json:"password" validate:"required" config:"PASSWORD"`
-	Host     string `json:"host" validate:"required" config:"HOST"`
-	Port     string `json:"port" validate:"required" config:"PORT"`
+	URL      string `json:"url" validate:"required" config:"URL" desc:"RabbitMQ URL"`
+	Username string `json:"username" validate:"required" config:"USERNAME" desc:"Username used to authenticate to RabbitMQ"`
+	Password string `json:"password" validate:"required" config:"PASSWORD" desc:"Password used to authenticate to RabbitMQ"`
+	Host     string `json:"host" validate:"required" config:"HOST" desc:"Hostname of the RabbitMQ broker"`
+	Port     string `json:"port" validate:"required" config:"PORT" desc:"Port of the RabbitMQ broker"`
 }
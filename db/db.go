@@ -14,15 +14,26 @@ import (
 	gorm "gorm.io/gorm"
 )
 
+// maxConnectRetryBackoff caps the exponential backoff between connection attempts.
+const maxConnectRetryBackoff = 30 * time.Second
+
 // DatabaseConnection represents a connection to a database.
 type DatabaseConnection struct {
-	db *gorm.DB
+	db       *gorm.DB
+	resolver *resolver
 }
 
 // Disconnect closes the underlying database connection associated with the DatabaseConnection instance.
 // It first obtains the underlying raw `*sql.DB` object by calling `d.db.DB()`, and then calls `Close()` on it.
 // If any error occurs during obtaining the raw database connection or while closing it, the error is returned.
+//
+// Read replicas connected through ConnectDBWithReplicas are closed first.
 func (d *DatabaseConnection) Disconnect() error {
+	if d.resolver != nil {
+		if err := d.resolver.close(); err != nil {
+			return err
+		}
+	}
 	sqlDb, err := d.db.DB()
 	if err != nil {
 		return err
@@ -38,9 +49,12 @@ func (d *DatabaseConnection) Raw() *gorm.DB {
 // ConnectDB establishes a connection to the database using the provided configuration.
 //
 // The function takes a `config.DatabaseConfig` object as input, which contains the necessary information to establish the connection.
-// It constructs a data source name (DSN) string based on the configuration properties.
+// It constructs a data source name (DSN) string based on the configuration properties, including the connect timeout,
+// statement timeout, application name and search path when set.
 //
-// The function then opens the connection to the database using the constructed DSN and the Gorm package.
+// The function then opens the connection to the database using the constructed DSN and the Gorm package,
+// retrying up to `ConnectRetries` times with an exponential backoff starting at `ConnectRetryBackoffMs`.
+// The gorm logger, batch size and connection pool are configured from the config, zero values fall back to the defaults.
 // It returns a `*DatabaseConnection` object, which encapsulates the Gorm DB object.
 //
 // If an error occurs during the connection process, it will be returned along with the `*DatabaseConnection` object as `nil`.
@@ -55,59 +69,98 @@ func (d *DatabaseConnection) Raw() *gorm.DB {
 //	    Password: "password",
 //	    DBName:   "mydb",
 //	    SSLMode:  "disable",
+//	    ConnectRetries: 3,
 //	}
 //	conn, err := ConnectDB(config)
 //	if err != nil {
 //	    fmt.Println("Failed to connect to the database:", err)
 //	} else {
-//	    defer conn.Close()
+//	    defer conn.Disconnect()
 //	    // Perform database operations using `conn`
 //	}
 func ConnectDB(config config.DatabaseConfig) (*DatabaseConnection, error) {
-	// DSN represents data source name for the database connection
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=%s", config.Host, config.User, config.Password, config.DBName, config.Port, config.SSLMode)
+	db, err := openDB(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// Print a message to the console if the connection is successful
+	logger_pkg.Info("db connected successfully")
+
+	// Return DatabaseConnection
+	return &DatabaseConnection{
+		db: db,
+	}, nil
+}
+
+// openDB opens and configures a gorm DB for the given configuration, retrying with backoff on failure.
+func openDB(config config.DatabaseConfig) (*gorm.DB, error) {
+	return openDBWith(config, false)
+}
+
+// openDBWith opens and configures a gorm DB, disablePing skips the connection check performed by gorm when opening.
+func openDBWith(config config.DatabaseConfig, disablePing bool) (*gorm.DB, error) {
+	config = withDefaults(config)
 
-	// Open the connection to the database
-	// db, err := sql.Open("postgres", dsn) // lib/pq version
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+	logLevel, err := parseLogLevel(config.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	gormConfig := &gorm.Config{
 		SkipDefaultTransaction: false,
 		NamingStrategy:         nil,
 		FullSaveAssociations:   false,
 		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
-			SlowThreshold:             time.Second,
+			SlowThreshold:             time.Duration(config.SlowThresholdMs) * time.Millisecond,
 			Colorful:                  true,
 			IgnoreRecordNotFoundError: false,
 			ParameterizedQueries:      false,
-			LogLevel:                  logger.Info,
+			LogLevel:                  logLevel,
 		}),
 		PrepareStmt:          true,
-		DisableAutomaticPing: false,
-		CreateBatchSize:      10,
-	})
-	if err != nil {
-		panic(err)
+		DisableAutomaticPing: disablePing,
+		CreateBatchSize:      config.CreateBatchSize,
+	}
+
+	var db *gorm.DB
+	backoff := time.Duration(config.ConnectRetryBackoffMs) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		// Open the connection to the database, gorm pings the database once opened
+		db, err = gorm.Open(postgres.Open(buildDSN(config)), gormConfig)
+		if err == nil {
+			break
+		}
+		// gorm keeps the pool opened when the ping fails, it is closed before the next attempt
+		if db != nil {
+			if sqlDB, dbErr := db.DB(); dbErr == nil {
+				_ = sqlDB.Close()
+			}
+		}
+		if attempt >= config.ConnectRetries {
+			return nil, fmt.Errorf("cannot connect to database %s after %d attempts: %w", config.Host, attempt+1, err)
+		}
+		logger_pkg.Warn("cannot connect to database %s (attempt %d/%d), retrying in %s: %s", config.Host, attempt+1, config.ConnectRetries+1, backoff, err)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxConnectRetryBackoff {
+			backoff = maxConnectRetryBackoff
+		}
 	}
 
 	// Create sqlDB
 	sqlDB, err := db.DB()
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	// SetMaxIdleConns sets the maximum number of connections in the idle connection pool.
-	sqlDB.SetMaxIdleConns(10)
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)
 
 	// SetMaxOpenConns sets the maximum number of open connections to the database.
-	sqlDB.SetMaxOpenConns(100)
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 
 	// SetConnMaxLifetime sets the maximum amount of time a connection may be reused.
-	sqlDB.SetConnMaxLifetime(time.Hour)
-
-	// Print a message to the console if the connection is successful
-	logger_pkg.Info("db connected successfully")
+	sqlDB.SetConnMaxLifetime(time.Duration(config.ConnMaxLifetimeSec) * time.Second)
 
-	// Return DatabaseConnection
-	return &DatabaseConnection{
-		db: db,
-	}, err
+	return db, nil
 }
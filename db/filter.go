@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Operator is a comparison operator of a Filter condition.
+type Operator string
+
+const (
+	OpEq     Operator = "eq"
+	OpNeq    Operator = "ne"
+	OpGt     Operator = "gt"
+	OpGte    Operator = "gte"
+	OpLt     Operator = "lt"
+	OpLte    Operator = "lte"
+	OpLike   Operator = "like"
+	OpIn     Operator = "in"
+	OpIsNull Operator = "null"
+)
+
+// condition is a single filter condition on a public field name.
+type condition struct {
+	field    string
+	operator Operator
+	value    any
+}
+
+// sortField is a single sort on a public field name.
+type sortField struct {
+	field string
+	desc  bool
+}
+
+// Filter is a typed filter and sort builder working on public field names, which a Repository resolves to columns
+// through its whitelist. Unknown fields are rejected, so a Filter built from a query string is safe to apply.
+//
+// Example usage:
+//
+//	filter := db.NewFilter().
+//	    Where("status", db.OpEq, "active").
+//	    Where("created", db.OpGte, since).
+//	    OrderBy("created", true)
+type Filter struct {
+	conditions []condition
+	sorts      []sortField
+}
+
+// NewFilter creates an empty Filter.
+func NewFilter() *Filter {
+	return &Filter{}
+}
+
+// Where adds a condition. OpIn expects a slice (or a "," separated string), OpIsNull a bool (or "true"/"false")
+// and OpLike a string matched case-insensitively anywhere in the column.
+func (f *Filter) Where(field string, operator Operator, value any) *Filter {
+	f.conditions = append(f.conditions, condition{field: field, operator: operator, value: value})
+	return f
+}
+
+// OrderBy adds a sort, applied in the order the sorts are added.
+func (f *Filter) OrderBy(field string, desc bool) *Filter {
+	f.sorts = append(f.sorts, sortField{field: field, desc: desc})
+	return f
+}
+
+// ListQuery holds the filter and sort query parameters of a list endpoint, to be embedded in the query type of a handler.
+//
+// The query string syntax is `?filter=<field>:<operator>:<value>&filter=<field>:<value>&sort=<field>,-<field>`
+// where the operator defaults to eq and a leading "-" sorts descending.
+//
+// Example usage:
+//
+//	type ListUsersQuery struct {
+//	    db.ListQuery
+//	}
+//	filter, err := data.Query.ToFilter()
+type ListQuery struct {
+	Filter []string `query:"filter" json:"filter"`
+	Sort   string   `query:"sort" json:"sort"`
+}
+
+// ToFilter parses the query parameters into a Filter.
+func (q ListQuery) ToFilter() (*Filter, error) {
+	return ParseFilter(q.Filter, q.Sort)
+}
+
+// ParseFilter parses filters of the form `<field>:<operator>:<value>` or `<field>:<value>` and a sort of the form
+// `<field>,-<field>`. The fields are checked against the whitelist of the Repository applying the Filter.
+func ParseFilter(filters []string, sort string) (*Filter, error) {
+	f := NewFilter()
+	for _, raw := range filters {
+		parts := strings.SplitN(raw, ":", 3)
+		switch len(parts) {
+		case 2:
+			f.Where(parts[0], OpEq, parts[1])
+		case 3:
+			operator := Operator(strings.ToLower(parts[1]))
+			if !isValidOperator(operator) {
+				return nil, invalidFilter("unknown operator %q", parts[1])
+			}
+			f.Where(parts[0], operator, parts[2])
+		default:
+			return nil, invalidFilter("invalid filter %q", raw)
+		}
+	}
+	for _, field := range strings.Split(sort, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if strings.HasPrefix(field, "-") {
+			f.OrderBy(field[1:], true)
+		} else {
+			f.OrderBy(strings.TrimPrefix(field, "+"), false)
+		}
+	}
+	return f, nil
+}
+
+// apply adds the conditions of the filter to db, resolving the fields through columns.
+func (f *Filter) apply(db *gorm.DB, columns map[string]string) (*gorm.DB, error) {
+	if f == nil {
+		return db, nil
+	}
+	for _, c := range f.conditions {
+		column, ok := columns[c.field]
+		if !ok {
+			return nil, invalidFilter("cannot filter on %q", c.field)
+		}
+		col := clause.Column{Name: column}
+		switch c.operator {
+		case OpEq:
+			db = db.Where(clause.Eq{Column: col, Value: c.value})
+		case OpNeq:
+			db = db.Where(clause.Neq{Column: col, Value: c.value})
+		case OpGt:
+			db = db.Where(clause.Gt{Column: col, Value: c.value})
+		case OpGte:
+			db = db.Where(clause.Gte{Column: col, Value: c.value})
+		case OpLt:
+			db = db.Where(clause.Lt{Column: col, Value: c.value})
+		case OpLte:
+			db = db.Where(clause.Lte{Column: col, Value: c.value})
+		case OpLike:
+			db = db.Where("? ILIKE ?", col, "%"+escapeLike(fmt.Sprint(c.value))+"%")
+		case OpIn:
+			values, ok := c.value.([]any)
+			if !ok {
+				values = make([]any, 0)
+				if s, isString := c.value.(string); isString {
+					for _, v := range strings.Split(s, ",") {
+						values = append(values, v)
+					}
+				} else {
+					values = append(values, c.value)
+				}
+			}
+			db = db.Where(clause.IN{Column: col, Values: values})
+		case OpIsNull:
+			isNull := c.value == true || c.value == "true"
+			if !isNull && c.value != false && c.value != "false" {
+				return nil, invalidFilter("invalid null value for %q", c.field)
+			}
+			if isNull {
+				db = db.Where("? IS NULL", col)
+			} else {
+				db = db.Where("? IS NOT NULL", col)
+			}
+		default:
+			return nil, invalidFilter("unknown operator %q", c.operator)
+		}
+	}
+	return db, nil
+}
+
+// orderColumns resolves the sorts of the filter to columns.
+func (f *Filter) orderColumns(columns map[string]string) ([]clause.OrderByColumn, error) {
+	if f == nil {
+		return nil, nil
+	}
+	order := make([]clause.OrderByColumn, 0, len(f.sorts))
+	for _, s := range f.sorts {
+		column, ok := columns[s.field]
+		if !ok {
+			return nil, invalidFilter("cannot sort on %q", s.field)
+		}
+		order = append(order, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: s.desc})
+	}
+	return order, nil
+}
+
+// isValidOperator reports whether the operator is known.
+func isValidOperator(operator Operator) bool {
+	switch operator {
+	case OpEq, OpNeq, OpGt, OpGte, OpLt, OpLte, OpLike, OpIn, OpIsNull:
+		return true
+	}
+	return false
+}
+
+// escapeLike escapes the LIKE wildcards of a user provided value.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// invalidFilter returns an error wrapping api_errors.ErrInvalidParams, so handlers answer with a 400.
+func invalidFilter(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", *api_errors.ErrInvalidParams, fmt.Sprintf(format, args...))
+}
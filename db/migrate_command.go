@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// RunMigrationCommand runs a migration sub command, so that services can expose their embedded migrations
+// through their own binary (e.g. `./service migrate up`). The supported commands are:
+// - up [version]: applies the pending migrations, up to version when given
+// - down [steps]: reverts the last steps migrations, 1 by default
+// - status: prints the state of every migration
+//
+// The `-dry-run` flag, placed before the command, logs the SQL instead of running it.
+//
+// Example usage:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+//	    if err := db.RunMigrationCommand(ctx, migrator, os.Args[2:], os.Stdout); err != nil {
+//	        logger.Fatal(err)
+//	    }
+//	    return
+//	}
+func RunMigrationCommand(ctx context.Context, m *Migrator, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "log the SQL instead of running it")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	m.WithDryRun(*dryRun)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("missing migration command: up, down or status")
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		var version int64
+		if flags.NArg() > 1 {
+			v, err := strconv.ParseInt(flags.Arg(1), 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid version %q", flags.Arg(1))
+			}
+			version = v
+		}
+		applied, err := m.UpTo(ctx, version)
+		if err != nil {
+			return err
+		}
+		return printMigrations(out, "up", applied, *dryRun)
+	case "down":
+		steps := 1
+		if flags.NArg() > 1 {
+			s, err := strconv.Atoi(flags.Arg(1))
+			if err != nil || s < 1 {
+				return fmt.Errorf("invalid steps %q", flags.Arg(1))
+			}
+			steps = s
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		return printMigrations(out, "down", reverted, *dryRun)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, status := range statuses {
+			state, appliedAt := "pending", ""
+			if status.Applied {
+				state = "applied"
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			if status.Missing {
+				state = "applied (missing)"
+			}
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migration command %q: expected up, down or status", flags.Arg(0))
+	}
+}
+
+// printMigrations prints the migrations handled by an up or down command.
+func printMigrations(out io.Writer, direction string, migrations []Migration, dryRun bool) error {
+	prefix := ""
+	if dryRun {
+		prefix = "[dry-run] would run "
+	}
+	if len(migrations) == 0 {
+		_, err := fmt.Fprintf(out, "%sno migrations to run %s\n", prefix, direction)
+		return err
+	}
+	for _, migration := range migrations {
+		if _, err := fmt.Fprintf(out, "%s%s %d_%s\n", prefix, direction, migration.Version, migration.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	logger_pkg "github.com/shiroyaavish/go-common/logger"
+)
+
+// DefaultMigrationsTable is the table recording the applied migrations.
+const DefaultMigrationsTable = "schema_migrations"
+
+// migrationFileRg matches migration files named `<version>_<name>.<up|down>.sql`.
+var migrationFileRg = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// tableNameRg restricts the migrations table to a plain (optionally schema qualified) identifier.
+var tableNameRg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// Migration is a versioned SQL migration.
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+}
+
+// MigrationStatus represents whether a migration has been applied.
+// Migrations recorded in the table but missing from the source are reported with Missing set.
+type MigrationStatus struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Missing   bool       `json:"missing,omitempty"`
+}
+
+// Migrator applies ordered up/down SQL migrations and records them in a schema table.
+//
+// Every run takes a Postgres advisory lock derived from the table name on a dedicated connection,
+// so pods starting concurrently wait for each other instead of racing. Every migration runs in its own
+// transaction together with its record in the table.
+//
+// Example usage:
+//
+//	//go:embed migrations/*.sql
+//	var migrationsFS embed.FS
+//
+//	migrator, err := db.NewMigrator(conn, migrationsFS, "migrations")
+//	if err != nil {
+//	    return err
+//	}
+//	applied, err := migrator.WithExtensions("uuid-ossp").Up(ctx)
+type Migrator struct {
+	conn       *DatabaseConnection
+	migrations []Migration
+	table      string
+	extensions []string
+	dryRun     bool
+}
+
+// NewMigrator loads the migrations found in dir of fsys (usually an embed.FS).
+// Files must be named `<version>_<name>.up.sql` and optionally `<version>_<name>.down.sql`.
+func NewMigrator(conn *DatabaseConnection, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		conn:       conn,
+		migrations: migrations,
+		table:      DefaultMigrationsTable,
+	}, nil
+}
+
+// LoadMigrations reads and orders the migrations found in dir of fsys.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := migrationFileRg.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		} else if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration version %d is used by %s and %s", version, migration.Name, match[2])
+		}
+		if match[3] == "up" {
+			migration.UpSQL = string(content)
+		} else {
+			migration.DownSQL = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.UpSQL == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// WithTable sets the table recording the applied migrations, defaults to DefaultMigrationsTable.
+func (m *Migrator) WithTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+// WithExtensions creates the given Postgres extensions (e.g. "uuid-ossp" for uuid_generate_v4()) before migrating.
+func (m *Migrator) WithExtensions(extensions ...string) *Migrator {
+	m.extensions = append(m.extensions, extensions...)
+	return m
+}
+
+// WithDryRun makes Up and Down log the SQL they would run without changing the database.
+func (m *Migrator) WithDryRun(dryRun bool) *Migrator {
+	m.dryRun = dryRun
+	return m
+}
+
+// Migrations returns the loaded migrations in order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration in order and returns the applied (or, in dry-run, pending) migrations.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo applies the pending migrations up to and including version, 0 applies all of them.
+func (m *Migrator) UpTo(ctx context.Context, version int64) ([]Migration, error) {
+	result := make([]Migration, 0)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.prepare(ctx, conn); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if version > 0 && migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if m.dryRun {
+				logger_pkg.Info("[dry-run] migration %d_%s up:\n%s", migration.Version, migration.Name, migration.UpSQL)
+			} else {
+				insert := fmt.Sprintf("INSERT INTO %s (version, name) VALUES ($1, $2)", m.table)
+				if err := runInTx(ctx, conn, migration.UpSQL, insert, migration.Version, migration.Name); err != nil {
+					return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+				}
+				logger_pkg.Info("applied migration %d_%s", migration.Version, migration.Name)
+			}
+			result = append(result, migration)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Down reverts the last steps applied migrations in reverse order and returns them.
+// It fails if one of them has no down file.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	result := make([]Migration, 0)
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		if err := m.prepare(ctx, conn); err != nil {
+			return err
+		}
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(result) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.DownSQL == "" {
+				return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+			}
+			if m.dryRun {
+				logger_pkg.Info("[dry-run] migration %d_%s down:\n%s", migration.Version, migration.Name, migration.DownSQL)
+			} else {
+				remove := fmt.Sprintf("DELETE FROM %s WHERE version = $1", m.table)
+				if err := runInTx(ctx, conn, migration.DownSQL, remove, migration.Version); err != nil {
+					return fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+				}
+				logger_pkg.Info("reverted migration %d_%s", migration.Version, migration.Name)
+			}
+			result = append(result, migration)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Status returns the state of every migration, including applied migrations missing from the source.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if !tableNameRg.MatchString(m.table) {
+		return nil, fmt.Errorf("invalid migrations table %q", m.table)
+	}
+	sqlDB, err := m.conn.db.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = &record.appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	for version, record := range applied {
+		if !known[version] {
+			statuses = append(statuses, MigrationStatus{Version: version, Name: record.name, Applied: true, AppliedAt: &record.appliedAt, Missing: true})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// appliedMigration is a row of the migrations table.
+type appliedMigration struct {
+	name      string
+	appliedAt time.Time
+}
+
+// withLock runs fn on a dedicated connection holding the advisory lock of the migrations table.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	if !tableNameRg.MatchString(m.table) {
+		return fmt.Errorf("invalid migrations table %q", m.table)
+	}
+	sqlDB, err := m.conn.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lockID := AdvisoryLockID("go_common:migrations:" + m.table)
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockID); err != nil {
+		return fmt.Errorf("cannot take migrations lock: %w", err)
+	}
+	defer func() {
+		// The lock is released with the session anyway, use a fresh context in case ctx is done
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			logger_pkg.Error(err, "cannot release migrations lock")
+		}
+	}()
+
+	return fn(conn)
+}
+
+// prepare creates the extensions and the migrations table, it only checks the table in dry-run.
+func (m *Migrator) prepare(ctx context.Context, conn *sql.Conn) error {
+	if m.dryRun {
+		for _, extension := range m.extensions {
+			logger_pkg.Info("[dry-run] CREATE EXTENSION IF NOT EXISTS %q", extension)
+		}
+		return nil
+	}
+	for _, extension := range m.extensions {
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %q", extension)); err != nil {
+			return fmt.Errorf("cannot create extension %s: %w", extension, err)
+		}
+	}
+	_, err := conn.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	version    BIGINT PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`, m.table))
+	return err
+}
+
+// applied returns the recorded migrations, an empty set if the table does not exist yet (dry-run).
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, "SELECT to_regclass($1) IS NOT NULL", m.table).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return applied, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT version, name, applied_at FROM %s", m.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var version int64
+		var record appliedMigration
+		if err := rows.Scan(&version, &record.name, &record.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = record
+	}
+	return applied, rows.Err()
+}
+
+// runInTx runs the migration SQL and its bookkeeping statement in a single transaction.
+func runInTx(ctx context.Context, conn *sql.Conn, migrationSQL, bookkeeping string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(migrationSQL) != "" {
+		if _, err := tx.ExecContext(ctx, migrationSQL); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, bookkeeping, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// AdvisoryLockID derives a stable Postgres advisory lock key from a name, to be used with pg_advisory_lock and friends.
+func AdvisoryLockID(name string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return int64(h.Sum64())
+}
@@ -0,0 +1,34 @@
+package db
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shiroyaavish/go-common/common"
+	gorm "gorm.io/gorm"
+)
+
+// BaseModel is the base of the models of every service: a UUID primary key generated by Postgres, the gorm
+// timestamps, soft deletion through DeletedAt and the audit columns filled by the model plugin (see UseModelPlugin).
+//
+// Example usage:
+//
+//	type Device struct {
+//	    db.BaseModel
+//	    Name string `json:"name"`
+//	}
+type BaseModel struct {
+	ID        uuid.UUID      `gorm:"primaryKey;type:uuid;default:gen_random_uuid()" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	CreatedBy *uuid.UUID     `gorm:"type:uuid" json:"created_by,omitempty"`
+	UpdatedBy *uuid.UUID     `gorm:"type:uuid" json:"updated_by,omitempty"`
+}
+
+// TenantModel is a BaseModel owned by a project, queries on it are scoped to the project carried by the context
+// when the model plugin is enabled.
+type TenantModel struct {
+	BaseModel
+	ProjectID common.Project `gorm:"index;not null" json:"project_id"`
+}
@@ -0,0 +1,363 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/datatypes"
+	logger_pkg "github.com/shiroyaavish/go-common/logger"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// ErrTenantMismatch is returned when a row is created for another project than the one carried by the context,
+// and by Repository.Upsert when the conflicting row belongs to another project.
+var ErrTenantMismatch = errors.New("db: row belongs to another project than the context")
+
+// skipTenantCtxKey is the context key set by WithoutTenant.
+type skipTenantCtxKey struct{}
+
+// History operations recorded by the model plugin.
+const (
+	HistoryCreate = "create"
+	HistoryUpdate = "update"
+	HistoryDelete = "delete"
+)
+
+// ModelPluginOptions configures the model plugin.
+// It contains the following fields:
+// - CreatedByColumn: the column receiving the access ID of the creator, defaults to "created_by"
+// - UpdatedByColumn: the column receiving the access ID of the last updater, defaults to "updated_by"
+// - TenantColumn: the column holding the common.Project of a row, defaults to "project_id"
+// - HistoryTable: when set, every create, update and delete is recorded in this table (see HistoryTableSQL)
+type ModelPluginOptions struct {
+	CreatedByColumn string
+	UpdatedByColumn string
+	TenantColumn    string
+	HistoryTable    string
+}
+
+// HistoryEntry is a row of the history table recorded by the model plugin.
+// Changes holds the created row, the updated columns or nothing for deletes.
+// RowID is empty when the rows of a batch update or delete are not known.
+type HistoryEntry struct {
+	ID        int64          `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Table     string         `gorm:"column:table_name" json:"table_name"`
+	RowID     string         `gorm:"column:row_id" json:"row_id"`
+	Operation string         `gorm:"column:operation" json:"operation"`
+	Changes   datatypes.JSON `gorm:"column:changes" json:"changes"`
+	ChangedBy *uuid.UUID     `gorm:"column:changed_by" json:"changed_by"`
+	ProjectID common.Project `gorm:"column:project_id" json:"project_id"`
+	ChangedAt time.Time      `gorm:"column:changed_at" json:"changed_at"`
+}
+
+// HistoryTableSQL returns the SQL creating the history table of the model plugin, to be added to the migrations of a service.
+func HistoryTableSQL(table string) (string, error) {
+	if !tableNameRg.MatchString(table) {
+		return "", fmt.Errorf("invalid history table %q", table)
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %[1]s (
+    id BIGSERIAL PRIMARY KEY,
+    table_name TEXT NOT NULL,
+    row_id TEXT NOT NULL DEFAULT '',
+    operation TEXT NOT NULL,
+    changes JSONB,
+    changed_by UUID,
+    project_id SMALLINT NOT NULL DEFAULT 0,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS %[2]s_row_idx ON %[1]s (table_name, row_id, changed_at);
+`, table, lastIdentifier(table)), nil
+}
+
+// lastIdentifier returns the table name without its schema.
+func lastIdentifier(table string) string {
+	for i := len(table) - 1; i >= 0; i-- {
+		if table[i] == '.' {
+			return table[i+1:]
+		}
+	}
+	return table
+}
+
+// WithoutTenant returns a context whose queries are not scoped to the project it carries, for administrative
+// and cross-project work. The audit columns are still filled.
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipTenantCtxKey{}, true)
+}
+
+// UseModelPlugin registers the model plugin on the connection. With it:
+// - created and updated rows get the access ID of the context (common.WithAccessId) in their CreatedByColumn and UpdatedByColumn
+// - when the context carries a project (common.WithProject), queries, updates and deletes of models with a TenantColumn are filtered on it and created rows get it assigned
+// - when a HistoryTable is set, creates, updates and deletes are recorded in it, in the same transaction
+//
+// The http_server handlers put the project and access ID of the request in RequestData.Ctx(). Raw SQL is not
+// scoped, use WithoutTenant to work across projects.
+//
+// Example usage:
+//
+//	if err := conn.UseModelPlugin(db.ModelPluginOptions{HistoryTable: "row_history"}); err != nil {
+//	    return err
+//	}
+//	conn.DB(data.Ctx()).Find(&devices) // WHERE devices.project_id = <Project-ID header>
+func (d *DatabaseConnection) UseModelPlugin(options ModelPluginOptions) error {
+	return d.db.Use(NewModelPlugin(options))
+}
+
+// NewModelPlugin creates the gorm plugin registered by UseModelPlugin, for gorm DBs opened outside of this package.
+func NewModelPlugin(options ModelPluginOptions) gorm.Plugin {
+	if options.CreatedByColumn == "" {
+		options.CreatedByColumn = "created_by"
+	}
+	if options.UpdatedByColumn == "" {
+		options.UpdatedByColumn = "updated_by"
+	}
+	if options.TenantColumn == "" {
+		options.TenantColumn = "project_id"
+	}
+	return &modelPlugin{options: options}
+}
+
+// modelPlugin is the gorm plugin filling the audit columns, scoping models to the tenant and recording history.
+type modelPlugin struct {
+	options ModelPluginOptions
+}
+
+// Name implements gorm.Plugin.
+func (p *modelPlugin) Name() string {
+	return "go_common:model"
+}
+
+// Initialize implements gorm.Plugin by registering the callbacks.
+func (p *modelPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []error{
+		callback.Create().Before("gorm:create").Register("go_common:model:before_create", p.beforeCreate),
+		callback.Query().Before("gorm:query").Register("go_common:model:tenant", p.scopeTenant),
+		callback.Row().Before("gorm:row").Register("go_common:model:tenant", p.scopeTenant),
+		callback.Update().Before("gorm:update").Register("go_common:model:before_update", p.beforeUpdate),
+		callback.Delete().Before("gorm:delete").Register("go_common:model:tenant", p.scopeTenantWrite),
+	}
+	if p.options.HistoryTable != "" {
+		if !tableNameRg.MatchString(p.options.HistoryTable) {
+			return fmt.Errorf("invalid history table %q", p.options.HistoryTable)
+		}
+		registrations = append(registrations,
+			callback.Create().After("gorm:create").Register("go_common:model:history", p.recordHistory(HistoryCreate)),
+			callback.Update().After("gorm:update").Register("go_common:model:history", p.recordHistory(HistoryUpdate)),
+			callback.Delete().After("gorm:delete").Register("go_common:model:history", p.recordHistory(HistoryDelete)),
+		)
+	}
+	return errors.Join(registrations...)
+}
+
+// skip reports whether the statement is out of the plugin scope: no model, or a write to the history table.
+func (p *modelPlugin) skip(db *gorm.DB) bool {
+	return db.Statement.Schema == nil || db.Error != nil ||
+		(p.options.HistoryTable != "" && db.Statement.Table == p.options.HistoryTable)
+}
+
+// tenant returns the project the statement must be scoped to, if any.
+func (p *modelPlugin) tenant(db *gorm.DB) (*schema.Field, common.Project, bool) {
+	ctx := db.Statement.Context
+	if skip, _ := ctx.Value(skipTenantCtxKey{}).(bool); skip {
+		return nil, common.UnknownProject, false
+	}
+	project, ok := common.ProjectFromContext(ctx)
+	if !ok {
+		return nil, common.UnknownProject, false
+	}
+	field := db.Statement.Schema.LookUpField(p.options.TenantColumn)
+	if field == nil {
+		return nil, common.UnknownProject, false
+	}
+	return field, project, true
+}
+
+// beforeCreate fills the audit columns and the tenant of the created rows.
+func (p *modelPlugin) beforeCreate(db *gorm.DB) {
+	if p.skip(db) {
+		return
+	}
+	ctx := db.Statement.Context
+	accessId, hasAccessId := common.AccessIdFromContext(ctx)
+	tenantField, project, hasTenant := p.tenant(db)
+
+	p.eachRow(db, func(row reflect.Value) {
+		if hasAccessId {
+			p.setIfZero(db, row, p.options.CreatedByColumn, accessId)
+			p.setIfZero(db, row, p.options.UpdatedByColumn, accessId)
+		}
+		if hasTenant {
+			value, isZero := tenantField.ValueOf(ctx, row)
+			if isZero {
+				db.AddError(tenantField.Set(ctx, row, project))
+			} else if !sameTenant(value, project) {
+				db.AddError(ErrTenantMismatch)
+			}
+		}
+	})
+}
+
+// beforeUpdate fills the updater audit column and scopes the update to the tenant.
+func (p *modelPlugin) beforeUpdate(db *gorm.DB) {
+	if p.skip(db) {
+		return
+	}
+	if accessId, ok := common.AccessIdFromContext(db.Statement.Context); ok && db.Statement.Schema.LookUpField(p.options.UpdatedByColumn) != nil {
+		db.Statement.SetColumn(p.options.UpdatedByColumn, accessId, true)
+	}
+	p.scopeTenantWrite(db)
+}
+
+// scopeTenant adds the tenant condition to the statement.
+func (p *modelPlugin) scopeTenant(db *gorm.DB) {
+	if p.skip(db) || db.Statement.SQL.Len() > 0 {
+		return
+	}
+	field, project, ok := p.tenant(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: project},
+	}})
+}
+
+// scopeTenantWrite adds the tenant condition to an update or a delete.
+// Statements without any condition are left alone, so that gorm still rejects them as global updates or deletes.
+func (p *modelPlugin) scopeTenantWrite(db *gorm.DB) {
+	if p.skip(db) || !p.hasConditions(db) {
+		return
+	}
+	p.scopeTenant(db)
+}
+
+// hasConditions reports whether an update or a delete already targets specific rows.
+func (p *modelPlugin) hasConditions(db *gorm.DB) bool {
+	stmt := db.Statement
+	if _, ok := stmt.Clauses["WHERE"]; ok || db.AllowGlobalUpdate {
+		return true
+	}
+	if stmt.Schema.PrioritizedPrimaryField == nil {
+		return false
+	}
+	switch stmt.ReflectValue.Kind() {
+	case reflect.Struct:
+		_, isZero := stmt.Schema.PrioritizedPrimaryField.ValueOf(stmt.Context, stmt.ReflectValue)
+		return !isZero
+	case reflect.Slice, reflect.Array:
+		return stmt.ReflectValue.Len() > 0
+	}
+	return false
+}
+
+// recordHistory returns the callback recording the operation of the statement in the history table.
+func (p *modelPlugin) recordHistory(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if p.skip(db) || db.Statement.RowsAffected == 0 {
+			return
+		}
+		stmt := db.Statement
+		ctx := stmt.Context
+		accessId, _ := common.AccessIdFromContext(ctx)
+		project, _ := common.ProjectFromContext(ctx)
+		entry := HistoryEntry{
+			Table:     stmt.Table,
+			Operation: operation,
+			ChangedBy: accessId,
+			ProjectID: project,
+			ChangedAt: time.Now(),
+		}
+
+		// Created rows are recorded whole, updates record their assignments
+		var changes any
+		if operation == HistoryUpdate {
+			changes = stmt.Dest
+		}
+
+		var entries []HistoryEntry
+		p.eachRow(db, func(row reflect.Value) {
+			e := entry
+			if field := stmt.Schema.PrioritizedPrimaryField; field != nil {
+				if value, isZero := field.ValueOf(ctx, row); !isZero {
+					e.RowID = fmt.Sprint(value)
+				}
+			}
+			rowChanges := changes
+			if operation == HistoryCreate {
+				rowChanges = row.Interface()
+			}
+			if rowChanges != nil {
+				data, err := json.Marshal(rowChanges)
+				if err != nil {
+					logger_pkg.Error(err, "cannot encode history of "+stmt.Table)
+				} else {
+					e.Changes = data
+				}
+			}
+			entries = append(entries, e)
+		})
+		if len(entries) == 0 {
+			// Batch updates and deletes through conditions, the rows are not known
+			e := entry
+			if changes != nil {
+				if data, err := json.Marshal(changes); err == nil {
+					e.Changes = data
+				}
+			}
+			entries = append(entries, e)
+		}
+
+		err := db.Session(&gorm.Session{NewDB: true, SkipHooks: true}).Table(p.options.HistoryTable).Create(&entries).Error
+		if err != nil {
+			db.AddError(fmt.Errorf("cannot record history of %s: %w", stmt.Table, err))
+		}
+	}
+}
+
+// eachRow calls fn for every struct row of the statement, rows given as maps are skipped.
+func (p *modelPlugin) eachRow(db *gorm.DB, fn func(row reflect.Value)) {
+	value := db.Statement.ReflectValue
+	switch value.Kind() {
+	case reflect.Struct:
+		if value.CanAddr() {
+			fn(value)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			row := reflect.Indirect(value.Index(i))
+			if row.Kind() == reflect.Struct && row.CanAddr() {
+				fn(row)
+			}
+		}
+	}
+}
+
+// setIfZero sets the column of the row to value when the model has it and it is not set yet.
+func (p *modelPlugin) setIfZero(db *gorm.DB, row reflect.Value, column string, value any) {
+	field := db.Statement.Schema.LookUpField(column)
+	if field == nil {
+		return
+	}
+	if _, isZero := field.ValueOf(db.Statement.Context, row); isZero {
+		db.AddError(field.Set(db.Statement.Context, row, value))
+	}
+}
+
+// sameTenant reports whether the tenant column value of a row is project.
+func sameTenant(value any, project common.Project) bool {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	t := reflect.TypeOf(project)
+	if v.IsValid() && v.CanConvert(t) {
+		return v.Convert(t).Interface() == project
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	logger_pkg "github.com/shiroyaavish/go-common/logger"
+)
+
+// maxNotifyPayload is the largest payload Postgres accepts in a notification.
+const maxNotifyPayload = 7999
+
+// errNotPgx is returned when the connection pool does not use the pgx driver, which notifications rely on.
+var errNotPgx = errors.New("db notifications require the pgx driver")
+
+// Notification is a Postgres notification whose payload was decoded into T.
+type Notification[T any] struct {
+	Channel string
+	PID     uint32
+	Payload T
+}
+
+// NotificationFunc handles a notification received by a Subscriber.
+type NotificationFunc[T any] func(ctx context.Context, n Notification[T])
+
+// SubscriberOptions configures a Subscriber.
+// It contains the following fields:
+// - ReconnectBackoff: the delay before the first reconnection attempt, doubled after every failed attempt, defaults to 500ms
+// - MaxReconnectBackoff: caps the reconnection backoff, defaults to 30 seconds
+type SubscriberOptions struct {
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+}
+
+// Subscriber listens to Postgres notifications on a set of channels and decodes their JSON payload into T.
+//
+// It holds a dedicated connection of the primary pool while listening, so MaxOpenConns must leave room for it.
+// When the connection is lost it reconnects with backoff and issues LISTEN again, notifications sent while
+// disconnected are lost: use notifications to trigger work (cache invalidation, wake ups), not as a durable queue.
+// A string T receives the raw payload, without JSON decoding.
+//
+// Example usage:
+//
+//	type DeviceChanged struct {
+//	    ID string `json:"id"`
+//	}
+//	subscriber := db.NewSubscriber[DeviceChanged](conn, "device_changed")
+//	subscriber.ListenAsync(ctx, func(ctx context.Context, n db.Notification[DeviceChanged]) {
+//	    cache.Delete(n.Payload.ID)
+//	})
+//	defer subscriber.Close()
+type Subscriber[T any] struct {
+	conn     *DatabaseConnection
+	channels []string
+	options  SubscriberOptions
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewSubscriber creates a Subscriber of the given channels.
+func NewSubscriber[T any](conn *DatabaseConnection, channels ...string) *Subscriber[T] {
+	return &Subscriber[T]{
+		conn:     conn,
+		channels: channels,
+	}
+}
+
+// WithOptions sets the options of the subscriber.
+func (s *Subscriber[T]) WithOptions(options SubscriberOptions) *Subscriber[T] {
+	s.options = options
+	return s
+}
+
+// Listen listens to the channels and calls fn for every notification until ctx is done or Close is called.
+// fn is called sequentially, a slow handler delays the following notifications.
+// Payloads that cannot be decoded into T are logged and skipped.
+func (s *Subscriber[T]) Listen(ctx context.Context, fn NotificationFunc[T]) error {
+	if len(s.channels) == 0 {
+		return errors.New("no channel to listen to")
+	}
+	backoff, maxBackoff := s.options.ReconnectBackoff, s.options.MaxReconnectBackoff
+	if backoff <= 0 {
+		backoff = 500 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+
+	wait := backoff
+	for {
+		listening := false
+		err := s.listen(ctx, fn, func() {
+			listening = true
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, errNotPgx) {
+			return err
+		}
+		if listening {
+			wait = backoff
+		}
+
+		logger_pkg.Error(err, fmt.Sprintf("db notification listener disconnected, reconnecting in %s", wait))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxBackoff)
+	}
+}
+
+// ListenAsync runs Listen in the background until ctx is done or Close is called. Calling it twice has no effect.
+func (s *Subscriber[T]) ListenAsync(ctx context.Context, fn NotificationFunc[T]) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		if err := s.Listen(ctx, fn); err != nil {
+			logger_pkg.Error(err, "db notification listener stopped")
+		}
+	}(s.done)
+}
+
+// Close stops the listener started by ListenAsync and waits for it to release its connection.
+func (s *Subscriber[T]) Close() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel, s.done = nil, nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// listen runs a single listening session on a dedicated connection, calling onListen once every LISTEN succeeded.
+// The connection is always discarded afterward rather than returned to the pool with active LISTENs.
+func (s *Subscriber[T]) listen(ctx context.Context, fn NotificationFunc[T], onListen func()) error {
+	sqlDB, err := s.conn.db.DB()
+	if err != nil {
+		return err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		stdConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("%w, got %T", errNotPgx, driverConn)
+		}
+		pgxConn := stdConn.Conn()
+
+		for _, channel := range s.channels {
+			if _, err := pgxConn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+		}
+		onListen()
+
+		for {
+			n, err := pgxConn.WaitForNotification(ctx)
+			if err != nil {
+				return errors.Join(err, driver.ErrBadConn)
+			}
+
+			notification := Notification[T]{Channel: n.Channel, PID: n.PID}
+			if raw, isString := any(&notification.Payload).(*string); isString {
+				*raw = n.Payload
+			} else if err := json.Unmarshal([]byte(n.Payload), &notification.Payload); err != nil {
+				logger_pkg.Error(err, "cannot decode db notification on "+n.Channel)
+				continue
+			}
+			fn(ctx, notification)
+		}
+	})
+}
+
+// Notify sends a notification on channel with payload encoded as JSON, strings and byte slices being sent as is.
+//
+// When ctx carries a transaction started by WithTx, the notification is part of it and only delivered on commit,
+// identical notifications of a transaction being delivered once. Postgres limits payloads to 8000 bytes.
+//
+// Example usage:
+//
+//	err := conn.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+//	    if err := tx.Save(&device).Error; err != nil {
+//	        return err
+//	    }
+//	    return conn.Notify(ctx, "device_changed", DeviceChanged{ID: device.ID})
+//	})
+func (d *DatabaseConnection) Notify(ctx context.Context, channel string, payload any) error {
+	var data string
+	switch p := payload.(type) {
+	case string:
+		data = p
+	case []byte:
+		data = string(p)
+	default:
+		encoded, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	if len(data) > maxNotifyPayload {
+		return fmt.Errorf("notification payload of %d bytes exceeds the %d bytes limit", len(data), maxNotifyPayload)
+	}
+	return d.DB(ctx).Exec("SELECT pg_notify(?, ?)", channel, data).Error
+}
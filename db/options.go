@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shiroyaavish/go-common/config"
+	"gorm.io/gorm/logger"
+)
+
+// withDefaults returns a copy of the configuration where the zero tuning fields are replaced by their defaults.
+func withDefaults(cfg config.DatabaseConfig) config.DatabaseConfig {
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if cfg.SlowThresholdMs == 0 {
+		cfg.SlowThresholdMs = 1000
+	}
+	if cfg.CreateBatchSize == 0 {
+		cfg.CreateBatchSize = 10
+	}
+	if cfg.MaxIdleConns == 0 {
+		cfg.MaxIdleConns = 10
+	}
+	if cfg.MaxOpenConns == 0 {
+		cfg.MaxOpenConns = 100
+	}
+	if cfg.ConnMaxLifetimeSec == 0 {
+		cfg.ConnMaxLifetimeSec = 3600
+	}
+	if cfg.ConnectTimeoutSec == 0 {
+		cfg.ConnectTimeoutSec = 10
+	}
+	if cfg.ConnectRetryBackoffMs == 0 {
+		cfg.ConnectRetryBackoffMs = 500
+	}
+	return cfg
+}
+
+// parseLogLevel converts the configured log level into a gorm log level.
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch strings.ToLower(level) {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "warn", "warning":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	default:
+		return logger.Silent, fmt.Errorf("invalid database log level %q", level)
+	}
+}
+
+// buildDSN builds the key/value data source name of the configuration.
+// The session settings (statement_timeout, application_name, search_path) are sent as runtime parameters.
+func buildDSN(cfg config.DatabaseConfig) string {
+	// DSN represents data source name for the database connection
+	params := [][2]string{
+		{"host", cfg.Host},
+		{"user", cfg.User},
+		{"password", cfg.Password},
+		{"dbname", cfg.DBName},
+		{"port", cfg.Port},
+		{"sslmode", cfg.SSLMode},
+		{"connect_timeout", fmt.Sprint(cfg.ConnectTimeoutSec)},
+	}
+	if cfg.StatementTimeoutMs > 0 {
+		params = append(params, [2]string{"statement_timeout", fmt.Sprint(cfg.StatementTimeoutMs)})
+	}
+	if cfg.ApplicationName != "" {
+		params = append(params, [2]string{"application_name", cfg.ApplicationName})
+	}
+	if cfg.SearchPath != "" {
+		params = append(params, [2]string{"search_path", cfg.SearchPath})
+	}
+
+	parts := make([]string, 0, len(params))
+	for _, param := range params {
+		parts = append(parts, fmt.Sprintf("%s=%s", param[0], quoteDSNValue(param[1])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes a DSN value when it is empty or contains spaces, quotes or backslashes.
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " '\\") {
+		return value
+	}
+	return "'" + strings.NewReplacer("\\", "\\\\", "'", "\\'").Replace(value) + "'"
+}
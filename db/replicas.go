@@ -0,0 +1,275 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shiroyaavish/go-common/config"
+	logger_pkg "github.com/shiroyaavish/go-common/logger"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// usePrimaryKey is the gorm setting and context key forcing queries to the primary.
+const usePrimaryKey = "go_common:use_primary"
+
+// primaryCtxKey is the type of the context key set by UsePrimary.
+type primaryCtxKey struct{}
+
+// ReplicaOptions configures the read replica routing of ConnectDBWithReplicas.
+// It contains the following fields:
+// - HealthCheckInterval: how often replicas are pinged, defaults to 10 seconds
+// - HealthCheckTimeout: the timeout of a single ping, defaults to 2 seconds
+type ReplicaOptions struct {
+	HealthCheckInterval time.Duration
+	HealthCheckTimeout  time.Duration
+}
+
+// replica is a read replica and its health.
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// resolver is a gorm plugin routing reads to healthy replicas and everything else to the primary, similar to gorm dbresolver.
+// Queries run on the primary when they are part of a transaction, when the SQL is not a SELECT,
+// or when the primary was forced through DatabaseConnection.Primary or UsePrimary.
+type resolver struct {
+	replicas []*replica
+	next     atomic.Uint64
+	options  ReplicaOptions
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Name implements gorm.Plugin.
+func (r *resolver) Name() string {
+	return "go_common:resolver"
+}
+
+// Initialize implements gorm.Plugin by registering the routing callbacks before queries and row scans.
+func (r *resolver) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Query().Before("gorm:query").Register("go_common:resolver", r.route); err != nil {
+		return err
+	}
+	return db.Callback().Row().Before("gorm:row").Register("go_common:resolver", r.route)
+}
+
+// route switches the connection pool of a read statement to a healthy replica.
+func (r *resolver) route(db *gorm.DB) {
+	stmt := db.Statement
+	if _, inTx := stmt.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if force, ok := stmt.Settings.Load(usePrimaryKey); ok && force == true {
+		return
+	}
+	if stmt.Context != nil {
+		if force, ok := stmt.Context.Value(primaryCtxKey{}).(bool); ok && force {
+			return
+		}
+	}
+	// Builder queries are routed before their SQL is built, locking reads are told by their clause.Locking.
+	// Only Raw queries already hold their SQL.
+	if _, locking := stmt.Clauses[clause.Locking{}.Name()]; locking {
+		return
+	}
+	if stmt.SQL.Len() > 0 && !isReadQuery(stmt.SQL.String()) {
+		return
+	}
+	if rep := r.pick(); rep != nil {
+		stmt.ConnPool = rep.db.Statement.ConnPool
+	}
+}
+
+// pick returns the next healthy replica in round-robin order, nil if none is healthy.
+func (r *resolver) pick() *replica {
+	total := uint64(len(r.replicas))
+	if total == 0 {
+		return nil
+	}
+	start := r.next.Add(1)
+	for i := uint64(0); i < total; i++ {
+		rep := r.replicas[(start+i)%total]
+		if rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+// healthCheck pings every replica each interval, removing unhealthy ones from the rotation and adding them back once they recover.
+func (r *resolver) healthCheck() {
+	ticker := time.NewTicker(r.options.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			for _, rep := range r.replicas {
+				r.checkReplica(rep)
+			}
+		}
+	}
+}
+
+// checkReplica pings a single replica and updates its health.
+func (r *resolver) checkReplica(rep *replica) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.options.HealthCheckTimeout)
+	defer cancel()
+
+	err := fmt.Errorf("no sql.DB")
+	if sqlDB, dbErr := rep.db.DB(); dbErr == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+
+	healthy := err == nil
+	if rep.healthy.Swap(healthy) != healthy {
+		if healthy {
+			logger_pkg.Info("db replica %s is healthy again", rep.name)
+		} else {
+			logger_pkg.Error(err, "db replica "+rep.name+" is unhealthy, removed from rotation")
+		}
+	}
+}
+
+// close stops the health check and closes the replica connections.
+func (r *resolver) close() error {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	var errs []string
+	for _, rep := range r.replicas {
+		sqlDB, err := rep.db.DB()
+		if err == nil {
+			err = sqlDB.Close()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", rep.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot close db replicas: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// isReadQuery reports whether a raw SQL statement only reads data.
+func isReadQuery(sql string) bool {
+	sql = strings.ToUpper(strings.TrimSpace(sql))
+	if !strings.HasPrefix(sql, "SELECT") && !strings.HasPrefix(sql, "WITH") {
+		return false
+	}
+	return !strings.Contains(sql, "FOR UPDATE") && !strings.Contains(sql, "FOR SHARE") &&
+		!strings.Contains(sql, "INSERT ") && !strings.Contains(sql, "UPDATE ") && !strings.Contains(sql, "DELETE ")
+}
+
+// ConnectDBWithReplicas establishes a connection to the primary database and to every read replica.
+//
+// Reads (gorm queries and SELECT raw queries) are spread round-robin over the healthy replicas,
+// writes, transactions and locking reads always run on the primary. Replicas are health-checked in the background
+// and removed from the rotation while they fail to answer, when no replica is healthy reads fall back to the primary.
+// Use Primary or UsePrimary to read your own writes.
+//
+// A replica that cannot be reached at startup is added as unhealthy instead of failing the connection,
+// it joins the rotation once it passes a health check. Only an unreachable primary returns an error.
+//
+// Example usage:
+//
+//	conn, err := db.ConnectDBWithReplicas(primaryConfig, []config.DatabaseConfig{replicaConfig}, db.ReplicaOptions{})
+//	if err != nil {
+//	    return err
+//	}
+//	conn.Raw().Find(&users)                       // replica
+//	conn.Raw().Create(&user)                      // primary
+//	conn.Primary().First(&user, user.ID)          // primary, read after write
+func ConnectDBWithReplicas(primary config.DatabaseConfig, replicas []config.DatabaseConfig, options ReplicaOptions) (*DatabaseConnection, error) {
+	if options.HealthCheckInterval <= 0 {
+		options.HealthCheckInterval = 10 * time.Second
+	}
+	if options.HealthCheckTimeout <= 0 {
+		options.HealthCheckTimeout = 2 * time.Second
+	}
+
+	conn, err := ConnectDB(primary)
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return conn, nil
+	}
+
+	r := &resolver{
+		options: options,
+		stop:    make(chan struct{}),
+	}
+	for i, replicaConfig := range replicas {
+		name := fmt.Sprintf("%d (%s)", i, replicaConfig.Host)
+		rep := &replica{name: name}
+
+		// Replicas are not retried at startup, the health check adds them back once reachable
+		replicaConfig.ConnectRetries = 0
+		rep.db, err = openDB(replicaConfig)
+		if err != nil {
+			// Open again without the automatic ping to keep a lazily connecting pool around
+			logger_pkg.Error(err, "db replica "+name+" is unreachable, added as unhealthy")
+			rep.db, err = openLazyDB(replicaConfig)
+			if err != nil {
+				_ = r.close()
+				_ = conn.Disconnect()
+				return nil, err
+			}
+		} else {
+			rep.healthy.Store(true)
+		}
+		r.replicas = append(r.replicas, rep)
+	}
+
+	if err := conn.db.Use(r); err != nil {
+		_ = r.close()
+		_ = conn.Disconnect()
+		return nil, err
+	}
+	conn.resolver = r
+	go r.healthCheck()
+
+	logger_pkg.Info("db connected with %d replicas", len(r.replicas))
+	return conn, nil
+}
+
+// openLazyDB opens a gorm DB without pinging the database, connections are established on first use.
+func openLazyDB(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	cfg.ConnectRetries = 0
+	return openDBWith(cfg, true)
+}
+
+// Primary returns a gorm DB whose queries always run on the primary, to read data right after writing it.
+func (d *DatabaseConnection) Primary() *gorm.DB {
+	return d.db.Set(usePrimaryKey, true)
+}
+
+// UsePrimary returns a context forcing every query run with it (through gorm's WithContext) to the primary.
+// It is meant to be set once per request after a write, so that the following reads see the data.
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryCtxKey{}, true)
+}
+
+// HealthyReplicas returns the number of replicas currently in the rotation.
+func (d *DatabaseConnection) HealthyReplicas() int {
+	if d.resolver == nil {
+		return 0
+	}
+	healthy := 0
+	for _, rep := range d.resolver.replicas {
+		if rep.healthy.Load() {
+			healthy++
+		}
+	}
+	return healthy
+}
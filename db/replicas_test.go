@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// fakePool is a gorm.ConnPool telling the primary and the replica apart, it is never called in dry run mode.
+type fakePool struct {
+	name string
+}
+
+func (p *fakePool) PrepareContext(context.Context, string) (*sql.Stmt, error) { return nil, nil }
+func (p *fakePool) ExecContext(context.Context, string, ...any) (sql.Result, error) {
+	return nil, nil
+}
+func (p *fakePool) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, nil
+}
+func (p *fakePool) QueryRowContext(context.Context, string, ...any) *sql.Row { return nil }
+
+// dryRunDB opens a dry run gorm DB on pool.
+func dryRunDB(t *testing.T, pool *fakePool) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: pool}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type routedModel struct {
+	ID   int
+	Name string
+}
+
+func TestResolverRoute(t *testing.T) {
+	primary, replicaPool := &fakePool{name: "primary"}, &fakePool{name: "replica"}
+	db := dryRunDB(t, primary)
+	rep := &replica{name: "replica", db: dryRunDB(t, replicaPool)}
+	rep.healthy.Store(true)
+	if err := db.Use(&resolver{replicas: []*replica{rep}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query func(db *gorm.DB) *gorm.DB
+		want  *fakePool
+	}{
+		{name: "find", query: func(db *gorm.DB) *gorm.DB { return db.Find(&[]routedModel{}) }, want: replicaPool},
+		{name: "raw select", query: func(db *gorm.DB) *gorm.DB { return db.Raw("SELECT * FROM routed_models").Find(&[]routedModel{}) }, want: replicaPool},
+		{name: "locking find", query: func(db *gorm.DB) *gorm.DB {
+			return db.Clauses(clause.Locking{Strength: "UPDATE"}).Find(&[]routedModel{})
+		}, want: primary},
+		{name: "raw locking select", query: func(db *gorm.DB) *gorm.DB {
+			return db.Raw("SELECT * FROM routed_models FOR UPDATE").Find(&[]routedModel{})
+		}, want: primary},
+		{name: "forced primary", query: func(db *gorm.DB) *gorm.DB {
+			return db.WithContext(UsePrimary(context.Background())).Find(&[]routedModel{})
+		}, want: primary},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := tt.query(db.Session(&gorm.Session{}))
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+			if got := result.Statement.ConnPool; got != tt.want {
+				t.Fatalf("expected the query to run on the %s, got %v", tt.want.name, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+package db
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/http_server"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// RepositoryOptions configures a Repository.
+// It contains the following fields:
+// - FilterColumns: the public field names usable in a Filter condition, mapped to their column
+// - SortColumns: the public field names usable in a Filter sort, mapped to their column
+// - KeyColumn: the unique column used as tie-breaker for sorting and keyset pagination, defaults to "id"
+// - ProjectColumn: the column holding the common.Project of a row, required by ForProject
+type RepositoryOptions struct {
+	FilterColumns map[string]string
+	SortColumns   map[string]string
+	KeyColumn     string
+	ProjectColumn string
+}
+
+// Repository is a generic gorm repository for the model T.
+//
+// Every method joins the transaction carried by ctx (see DatabaseConnection.WithTx). Lists accept the
+// http_server.Pagination of the request directly and support both offset and keyset (cursor) pagination,
+// and take a Filter whose fields are checked against the whitelists of the options.
+//
+// Example usage:
+//
+//	users := db.NewRepository[User](conn, db.RepositoryOptions{
+//	    FilterColumns: map[string]string{"email": "email", "status": "status"},
+//	    SortColumns:   map[string]string{"created": "created_at"},
+//	    ProjectColumn: "project_id",
+//	})
+//
+//	// in a paginated handler
+//	filter, err := data.Query.ToFilter()
+//	if err != nil {
+//	    return nil, err
+//	}
+//	return users.ForProject(data.ProjectID).List(ctx, data.Pagination, filter)
+type Repository[T any] struct {
+	conn    *DatabaseConnection
+	options RepositoryOptions
+	project common.Project
+
+	schemaOnce sync.Once
+	schema     *schema.Schema
+	schemaErr  error
+}
+
+// NewRepository creates a Repository for the model T.
+func NewRepository[T any](conn *DatabaseConnection, options RepositoryOptions) *Repository[T] {
+	if options.KeyColumn == "" {
+		options.KeyColumn = "id"
+	}
+	return &Repository[T]{
+		conn:    conn,
+		options: options,
+	}
+}
+
+// ForProject returns a copy of the repository scoped to the given project: every query is filtered on
+// the ProjectColumn and created or upserted rows get the project assigned.
+// It panics if the options have no ProjectColumn.
+func (r *Repository[T]) ForProject(project common.Project) *Repository[T] {
+	if r.options.ProjectColumn == "" {
+		panic("db: Repository.ForProject requires RepositoryOptions.ProjectColumn")
+	}
+	return &Repository[T]{
+		conn:    r.conn,
+		options: r.options,
+		project: project,
+	}
+}
+
+// DB returns the gorm DB of the repository bound to ctx, joining its transaction and scoped to the project.
+func (r *Repository[T]) DB(ctx context.Context) *gorm.DB {
+	return r.scoped(r.conn.DB(ctx).Model(new(T)))
+}
+
+// scoped adds the project condition of the repository to db.
+func (r *Repository[T]) scoped(db *gorm.DB) *gorm.DB {
+	if r.project != common.UnknownProject {
+		db = db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.options.ProjectColumn}, Value: r.project})
+	}
+	return db
+}
+
+// Get returns the row with the given primary key, gorm.ErrRecordNotFound if there is none.
+func (r *Repository[T]) Get(ctx context.Context, id any) (*T, error) {
+	entity := new(T)
+	if err := r.DB(ctx).Where(clause.Eq{Column: r.keyColumn(), Value: id}).Take(entity).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// List returns the rows matching the filter, sorted by the filter sorts and the KeyColumn.
+//
+// With a nil pagination every row is returned. Otherwise pagination.Limit rows are returned, starting after
+// pagination.Cursor when it is set (keyset) or skipping pagination.Offset rows. When the look-ahead row added by
+// RequestHandlerBuilder is present, pagination.NextCursor is set to the cursor of the last row of the page.
+// Cursor pagination requires the sort columns to be non nullable.
+func (r *Repository[T]) List(ctx context.Context, pagination *http_server.Pagination, filter *Filter) ([]T, error) {
+	db, err := filter.apply(r.DB(ctx), r.options.FilterColumns)
+	if err != nil {
+		return nil, err
+	}
+	order, err := filter.orderColumns(r.options.SortColumns)
+	if err != nil {
+		return nil, err
+	}
+	order = append(order, clause.OrderByColumn{Column: r.keyColumn(), Desc: len(order) > 0 && order[len(order)-1].Desc})
+
+	if pagination != nil {
+		if pagination.Cursor != "" {
+			db, err = applyCursor(db, order, pagination.Cursor)
+			if err != nil {
+				return nil, err
+			}
+		} else if pagination.Offset > 0 {
+			db = db.Offset(pagination.Offset)
+		}
+		if pagination.Limit > 0 {
+			db = db.Limit(pagination.Limit)
+		}
+	}
+
+	items := make([]T, 0)
+	if err := db.Order(clause.OrderBy{Columns: order}).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	if pagination != nil && pagination.Limit > 1 && len(items) == pagination.Limit {
+		pagination.NextCursor, err = r.cursorOf(ctx, &items[len(items)-2], order)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// Count returns the number of rows matching the filter.
+func (r *Repository[T]) Count(ctx context.Context, filter *Filter) (int64, error) {
+	db, err := filter.apply(r.DB(ctx), r.options.FilterColumns)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	return count, db.Count(&count).Error
+}
+
+// Create inserts the entity, assigning the project of the repository.
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	if err := r.assignProject(ctx, entity); err != nil {
+		return err
+	}
+	return r.conn.DB(ctx).Create(entity).Error
+}
+
+// Update updates the entity by primary key. Only the given columns are updated, every column when none is given.
+// The primary key, the project and the created_* columns keep their values.
+// It returns gorm.ErrMissingWhereClause when the primary key of the entity is zero, so that a scoped repository
+// never updates every row of its project, and gorm.ErrRecordNotFound when no row was updated.
+func (r *Repository[T]) Update(ctx context.Context, entity *T, columns ...string) error {
+	s, err := r.parsedSchema()
+	if err != nil {
+		return err
+	}
+	if len(s.PrimaryFields) == 0 {
+		return gorm.ErrMissingWhereClause
+	}
+	for _, field := range s.PrimaryFields {
+		if _, zero := field.ValueOf(ctx, reflect.ValueOf(entity)); zero {
+			return gorm.ErrMissingWhereClause
+		}
+	}
+
+	db := r.scoped(r.conn.DB(ctx).Model(entity))
+	if len(columns) > 0 {
+		db = db.Select(columns)
+	} else {
+		db = db.Select("*")
+	}
+	result := db.Omit(r.protectedColumns(s)...).Updates(entity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SoftDelete deletes the row with the given primary key. Models with a gorm.DeletedAt field are soft deleted,
+// other models are deleted permanently. It returns gorm.ErrRecordNotFound when no row was deleted.
+func (r *Repository[T]) SoftDelete(ctx context.Context, id any) error {
+	result := r.DB(ctx).Where(clause.Eq{Column: r.keyColumn(), Value: id}).Delete(new(T))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Upsert inserts the entity or, when a row conflicts on conflictColumns (the primary key when none is given),
+// updates the columns of that row. The primary key, the project and the created_* columns keep their values.
+// On a repository scoped with ForProject only a row of the same project is updated, a conflict with a row of
+// another project returns ErrTenantMismatch.
+func (r *Repository[T]) Upsert(ctx context.Context, entity *T, conflictColumns ...string) error {
+	if err := r.assignProject(ctx, entity); err != nil {
+		return err
+	}
+	s, err := r.parsedSchema()
+	if err != nil {
+		return err
+	}
+	onConflict := clause.OnConflict{DoUpdates: clause.AssignmentColumns(r.upsertColumns(s))}
+	for _, column := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: column})
+	}
+	if len(onConflict.DoUpdates) == 0 {
+		onConflict.DoNothing = true
+	}
+	if r.project != common.UnknownProject {
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.options.ProjectColumn}, Value: r.project},
+		}}
+	}
+
+	result := r.conn.DB(ctx).Clauses(onConflict).Create(entity)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 && r.project != common.UnknownProject && !onConflict.DoNothing {
+		return ErrTenantMismatch
+	}
+	return nil
+}
+
+// upsertColumns returns the columns updated by Upsert on a conflict: the columns gorm would update with
+// clause.OnConflict.UpdateAll, except the protected columns.
+func (r *Repository[T]) upsertColumns(s *schema.Schema) []string {
+	columns := make([]string, 0, len(s.DBNames))
+	for _, name := range s.DBNames {
+		field := s.FieldsByDBName[name]
+		switch {
+		case r.isProtected(field), !field.Creatable:
+			continue
+		case field.HasDefaultValue && field.DefaultValueInterface == nil:
+			// Left out of the INSERT when zero, the excluded row would hold the default
+			continue
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+// protectedColumns returns the columns Update and Upsert never change.
+func (r *Repository[T]) protectedColumns(s *schema.Schema) []string {
+	var columns []string
+	for _, name := range s.DBNames {
+		if r.isProtected(s.FieldsByDBName[name]) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// isProtected reports whether the column keeps its value once the row is created: the primary key, the project,
+// the created_* columns and the columns that are not updatable.
+func (r *Repository[T]) isProtected(field *schema.Field) bool {
+	return field.PrimaryKey || !field.Updatable || field.AutoCreateTime > 0 ||
+		field.DBName == r.options.ProjectColumn || strings.HasPrefix(field.DBName, "created_")
+}
+
+// keyColumn returns the tie-breaker column qualified with the current table.
+func (r *Repository[T]) keyColumn() clause.Column {
+	return clause.Column{Table: clause.CurrentTable, Name: r.options.KeyColumn}
+}
+
+// parsedSchema returns the gorm schema of T.
+func (r *Repository[T]) parsedSchema() (*schema.Schema, error) {
+	r.schemaOnce.Do(func() {
+		stmt := &gorm.Statement{DB: r.conn.db}
+		r.schemaErr = stmt.Parse(new(T))
+		r.schema = stmt.Schema
+	})
+	return r.schema, r.schemaErr
+}
+
+// assignProject sets the project of the repository on the ProjectColumn field of the entity.
+func (r *Repository[T]) assignProject(ctx context.Context, entity *T) error {
+	if r.project == common.UnknownProject {
+		return nil
+	}
+	s, err := r.parsedSchema()
+	if err != nil {
+		return err
+	}
+	field := s.LookUpField(r.options.ProjectColumn)
+	if field == nil {
+		return fmt.Errorf("db: model %s has no column %s", s.Name, r.options.ProjectColumn)
+	}
+	return field.Set(ctx, reflect.ValueOf(entity), r.project)
+}
+
+// cursorOf encodes the values of the order columns of the entity as an opaque cursor.
+func (r *Repository[T]) cursorOf(ctx context.Context, entity *T, order []clause.OrderByColumn) (string, error) {
+	s, err := r.parsedSchema()
+	if err != nil {
+		return "", err
+	}
+	values := make([]string, 0, len(order))
+	for _, o := range order {
+		field := s.LookUpField(o.Column.Name)
+		if field == nil {
+			return "", fmt.Errorf("db: model %s has no column %s", s.Name, o.Column.Name)
+		}
+		value, _ := field.ValueOf(ctx, reflect.ValueOf(entity).Elem())
+		values = append(values, cursorValue(value))
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// applyCursor adds the keyset condition selecting the rows after the cursor for the given order, expanded as
+// (c1 > v1) OR (c1 = v1 AND c2 > v2) OR ... so that mixed sort directions are supported.
+func applyCursor(db *gorm.DB, order []clause.OrderByColumn, cursor string) (*gorm.DB, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid cursor", *api_errors.ErrInvalidParams)
+	}
+	var values []string
+	if err := json.Unmarshal(data, &values); err != nil || len(values) != len(order) {
+		return nil, fmt.Errorf("%w: invalid cursor", *api_errors.ErrInvalidParams)
+	}
+
+	branches := make([]clause.Expression, 0, len(order))
+	for i, o := range order {
+		exprs := make([]clause.Expression, 0, i+1)
+		for j := 0; j < i; j++ {
+			exprs = append(exprs, clause.Eq{Column: order[j].Column, Value: values[j]})
+		}
+		if o.Desc {
+			exprs = append(exprs, clause.Lt{Column: o.Column, Value: values[i]})
+		} else {
+			exprs = append(exprs, clause.Gt{Column: o.Column, Value: values[i]})
+		}
+		branches = append(branches, clause.And(exprs...))
+	}
+	return db.Where(clause.Or(branches...)), nil
+}
+
+// cursorValue formats a column value in the text format understood by Postgres.
+func cursorValue(value any) string {
+	rv := reflect.ValueOf(value)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return ""
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return ""
+	}
+	if valuer, ok := rv.Interface().(driver.Valuer); ok {
+		if v, err := valuer.Value(); err == nil && v != nil {
+			rv = reflect.ValueOf(v)
+		}
+	}
+	if t, ok := rv.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339Nano)
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(rv.Bool())
+	case reflect.String:
+		return rv.String()
+	}
+	if stringer, ok := rv.Interface().(fmt.Stringer); ok {
+		return stringer.String()
+	}
+	return fmt.Sprint(rv.Interface())
+}
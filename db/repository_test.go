@@ -0,0 +1,109 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shiroyaavish/go-common/common"
+	gorm "gorm.io/gorm"
+)
+
+type upsertedModel struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	ProjectID common.Project
+	Name      string
+	CreatedAt time.Time
+	CreatedBy *uuid.UUID
+	UpdatedAt time.Time
+}
+
+func TestRepositoryUpsert(t *testing.T) {
+	db := dryRunDB(t, &fakePool{name: "primary"})
+	var sql string
+	err := db.Callback().Create().After("gorm:create").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := NewRepository[upsertedModel](&DatabaseConnection{db: db}, RepositoryOptions{ProjectColumn: "project_id"})
+
+	// The dry run affects no row, as when the conflicting row belongs to another project
+	err = repository.ForProject(common.Project(1)).Upsert(context.Background(), &upsertedModel{ID: uuid.New(), Name: "name"}, "id")
+	if err != ErrTenantMismatch {
+		t.Fatalf("expected ErrTenantMismatch, got %v", err)
+	}
+
+	_, updates, found := strings.Cut(sql, "DO UPDATE SET")
+	if !found {
+		t.Fatalf("expected an ON CONFLICT DO UPDATE, got %s", sql)
+	}
+	for _, column := range []string{`"name"`, `"updated_at"`} {
+		if !strings.Contains(updates, column+"=") {
+			t.Errorf("expected %s to be updated: %s", column, updates)
+		}
+	}
+	for _, column := range []string{`"id"=`, `"project_id"=`, `"created_at"=`, `"created_by"=`} {
+		if strings.Contains(updates, column) {
+			t.Errorf("expected %s to keep its value: %s", column, updates)
+		}
+	}
+	if !strings.Contains(updates, `WHERE "upserted_models"."project_id" = $`) {
+		t.Errorf("expected the update to be restricted to the project: %s", updates)
+	}
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	db := dryRunDB(t, &fakePool{name: "primary"})
+	var sql string
+	err := db.Callback().Update().After("gorm:update").Register("test:capture", func(tx *gorm.DB) {
+		sql = tx.Statement.SQL.String()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := NewRepository[upsertedModel](&DatabaseConnection{db: db}, RepositoryOptions{ProjectColumn: "project_id"}).
+		ForProject(common.Project(1))
+
+	t.Run("zero primary key", func(t *testing.T) {
+		sql = ""
+		err := repository.Update(context.Background(), &upsertedModel{Name: "name"})
+		if err != gorm.ErrMissingWhereClause {
+			t.Fatalf("expected gorm.ErrMissingWhereClause, got %v", err)
+		}
+		if sql != "" {
+			t.Fatalf("expected no query, got %s", sql)
+		}
+	})
+
+	t.Run("every column", func(t *testing.T) {
+		id := uuid.New()
+		// The dry run affects no row
+		err := repository.Update(context.Background(), &upsertedModel{ID: id, Name: "name"})
+		if err != gorm.ErrRecordNotFound {
+			t.Fatalf("expected gorm.ErrRecordNotFound, got %v", err)
+		}
+		set, where, found := strings.Cut(sql, "WHERE")
+		if !found {
+			t.Fatalf("expected a WHERE clause, got %s", sql)
+		}
+		for _, column := range []string{`"name"=`, `"updated_at"=`} {
+			if !strings.Contains(set, column) {
+				t.Errorf("expected %s to be updated: %s", column, set)
+			}
+		}
+		for _, column := range []string{`"id"=`, `"project_id"=`, `"created_at"=`, `"created_by"=`} {
+			if strings.Contains(set, column) {
+				t.Errorf("expected %s to keep its value: %s", column, set)
+			}
+		}
+		for _, condition := range []string{`"upserted_models"."project_id" =`, `"id" =`} {
+			if !strings.Contains(where, condition) {
+				t.Errorf("expected the update to be restricted by %s: %s", condition, where)
+			}
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// term is a single element of a parsed search: a word, a quoted phrase or an OR operator.
+type term struct {
+	lexemes []string
+	phrase  bool
+	negated bool
+	or      bool
+}
+
+// ToTsQuery turns free-form user input into a tsquery, to be passed as a parameter to to_tsquery.
+//
+// The input follows the usual web search syntax:
+// - words are ANDed: `red car` becomes `red & car`
+// - quoted text is a phrase: `"red car"` becomes `(red <-> car)`
+// - a leading "-" negates a word or phrase: `car -red` becomes `car & !red`
+// - an uppercase OR between two terms ORs them: `red OR blue` becomes `red | blue`
+//
+// Only letters and digits are kept, any other character separates lexemes, so that the result is always a valid tsquery
+// whatever the input. A word broken apart this way (`e-mail`) becomes a phrase of its parts.
+// When prefix is true, words (not phrases nor negations) also match lexemes starting with them, for search as you type.
+// An input without any letter or digit returns an empty string.
+//
+// Example usage:
+//
+//	search.ToTsQuery(`"living room" lamp -broken`, true) // (living <-> room) & lamp:* & !broken
+func ToTsQuery(input string, prefix bool) string {
+	var builder strings.Builder
+	pendingOr := false
+	for _, t := range parse(input) {
+		if t.or {
+			pendingOr = builder.Len() > 0
+			continue
+		}
+		if builder.Len() > 0 {
+			if pendingOr {
+				builder.WriteString(" | ")
+			} else {
+				builder.WriteString(" & ")
+			}
+		}
+		pendingOr = false
+
+		if t.negated {
+			builder.WriteString("!")
+		}
+		lexemes := t.lexemes
+		if prefix && !t.phrase && !t.negated {
+			lexemes = append([]string(nil), lexemes...)
+			lexemes[len(lexemes)-1] += ":*"
+		}
+		if len(lexemes) == 1 {
+			builder.WriteString(lexemes[0])
+		} else {
+			builder.WriteString("(" + strings.Join(lexemes, " <-> ") + ")")
+		}
+	}
+	return builder.String()
+}
+
+// parse splits the input into terms, dropping terms without any lexeme.
+func parse(input string) []term {
+	var terms []term
+	runes := []rune(input)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		negated := false
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			negated = true
+			i++
+		}
+
+		var raw string
+		phrase := runes[i] == '"'
+		if phrase {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i+1 : end])
+			i = end + 1
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && runes[end] != '"' {
+				end++
+			}
+			raw = string(runes[i:end])
+			i = end
+		}
+
+		if raw == "OR" && !phrase && !negated {
+			terms = append(terms, term{or: true})
+			continue
+		}
+		lexemes := lexemesOf(raw)
+		if len(lexemes) == 0 {
+			continue
+		}
+		terms = append(terms, term{lexemes: lexemes, phrase: phrase, negated: negated})
+	}
+	return terms
+}
+
+// lexemesOf lowercases s and splits it on every character that is not a letter or a digit.
+func lexemesOf(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,165 @@
+// Package search provides Postgres full-text search helpers for gorm: a safe tsquery builder for user input,
+// scopes for matching, ranking and highlighting, and DDL helpers for tsvector columns and their GIN indexes.
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Language is a Postgres text search configuration, deciding how words are normalized (stemming, stop words).
+type Language string
+
+const (
+	Simple     Language = "simple"
+	English    Language = "english"
+	French     Language = "french"
+	German     Language = "german"
+	Spanish    Language = "spanish"
+	Italian    Language = "italian"
+	Portuguese Language = "portuguese"
+	Dutch      Language = "dutch"
+	Russian    Language = "russian"
+)
+
+// Searcher builds full-text search scopes on a tsvector column.
+//
+// Example usage:
+//
+//	s := search.New("search_vector").WithLanguage(search.English)
+//	var devices []Device
+//	err := conn.Raw().
+//	    Scopes(s.Match(input), s.Rank(input), s.Headline(input, "description", "snippet", search.HeadlineOptions{})).
+//	    Find(&devices).Error
+type Searcher struct {
+	column   string
+	language Language
+	prefix   bool
+}
+
+// New creates a Searcher on the tsvector column, using the English configuration and prefix matching.
+func New(column string) *Searcher {
+	return &Searcher{
+		column:   column,
+		language: English,
+		prefix:   true,
+	}
+}
+
+// WithLanguage sets the text search configuration, it must match the one the tsvector column is built with.
+func (s *Searcher) WithLanguage(language Language) *Searcher {
+	s.language = language
+	return s
+}
+
+// WithPrefix enables or disables prefix matching of the words of the input, enabled by default.
+func (s *Searcher) WithPrefix(prefix bool) *Searcher {
+	s.prefix = prefix
+	return s
+}
+
+// Query returns the tsquery text of the input, see ToTsQuery.
+func (s *Searcher) Query(input string) string {
+	return ToTsQuery(input, s.prefix)
+}
+
+// tsQuery returns the to_tsquery expression of the input.
+func (s *Searcher) tsQuery(input string) clause.Expr {
+	return gorm.Expr("to_tsquery(?::regconfig, ?)", string(s.language), s.Query(input))
+}
+
+// Match returns a scope keeping the rows whose tsvector column matches the input.
+// An input without any searchable word does not filter anything.
+func (s *Searcher) Match(input string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if s.Query(input) == "" {
+			return db
+		}
+		return db.Where("? @@ ?", clause.Column{Name: s.column}, s.tsQuery(input))
+	}
+}
+
+// RankExpr returns the ts_rank expression of the rows against the input, to be used in a Select or an Order.
+func (s *Searcher) RankExpr(input string) clause.Expr {
+	return gorm.Expr("ts_rank(?, ?)", clause.Column{Name: s.column}, s.tsQuery(input))
+}
+
+// Rank returns a scope ordering the rows by their ts_rank against the input, best matches first.
+// It is meant to be combined with Match, orders added afterward break the ties.
+func (s *Searcher) Rank(input string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if s.Query(input) == "" {
+			return db
+		}
+		return db.Order(clause.OrderBy{Expression: gorm.Expr("? DESC", s.RankExpr(input))})
+	}
+}
+
+// SelectRank returns a scope selecting every column plus the ts_rank of the rows as alias, to expose the score.
+func (s *Searcher) SelectRank(input, alias string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("*, ? AS ?", s.RankExpr(input), clause.Column{Name: alias})
+	}
+}
+
+// HeadlineOptions configures the snippets generated by ts_headline, zero values keep the Postgres defaults.
+// It contains the following fields:
+// - StartSel, StopSel: the markers around matched words, `<b>` and `</b>` by default
+// - MaxWords, MinWords: the bounds of the snippet length in words
+// - MaxFragments: when greater than 0, the number of fragments to extract instead of a single snippet
+// - FragmentDelimiter: the separator of fragments, ` ... ` by default
+type HeadlineOptions struct {
+	StartSel          string
+	StopSel           string
+	MaxWords          int
+	MinWords          int
+	MaxFragments      int
+	FragmentDelimiter string
+}
+
+// String returns the options in the ts_headline syntax.
+func (o HeadlineOptions) String() string {
+	var options []string
+	quote := func(value string) string {
+		return `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	}
+	if o.StartSel != "" {
+		options = append(options, "StartSel="+quote(o.StartSel))
+	}
+	if o.StopSel != "" {
+		options = append(options, "StopSel="+quote(o.StopSel))
+	}
+	if o.MaxWords > 0 {
+		options = append(options, "MaxWords="+strconv.Itoa(o.MaxWords))
+	}
+	if o.MinWords > 0 {
+		options = append(options, "MinWords="+strconv.Itoa(o.MinWords))
+	}
+	if o.MaxFragments > 0 {
+		options = append(options, "MaxFragments="+strconv.Itoa(o.MaxFragments))
+	}
+	if o.FragmentDelimiter != "" {
+		options = append(options, "FragmentDelimiter="+quote(o.FragmentDelimiter))
+	}
+	return strings.Join(options, ", ")
+}
+
+// HeadlineExpr returns the ts_headline expression highlighting the words of the input in the document column.
+// ts_headline works on the original text, so document is a text column, not the tsvector.
+func (s *Searcher) HeadlineExpr(input, document string, options HeadlineOptions) clause.Expr {
+	return gorm.Expr("ts_headline(?::regconfig, ?, ?, ?)",
+		string(s.language), clause.Column{Name: document}, s.tsQuery(input), options.String())
+}
+
+// Headline returns a scope selecting every column plus the snippet of HeadlineExpr as alias.
+// Each select scope replaces the selected columns, to get both the rank and a snippet use the expressions directly:
+//
+//	db.Select("*, ? AS rank, ? AS snippet", s.RankExpr(input), s.HeadlineExpr(input, "description", search.HeadlineOptions{}))
+func (s *Searcher) Headline(input, document, alias string, options HeadlineOptions) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Select("*, ? AS ?", s.HeadlineExpr(input, document, options), clause.Column{Name: alias})
+	}
+}
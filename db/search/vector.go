@@ -0,0 +1,151 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/shiroyaavish/go-common/db"
+	gorm "gorm.io/gorm"
+)
+
+// identifierRg restricts the languages, tables and columns written into DDL to plain (optionally schema qualified) identifiers.
+var identifierRg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// Weight is the tsvector weight of a field, A ranking highest and D lowest.
+type Weight string
+
+const (
+	WeightA Weight = "A"
+	WeightB Weight = "B"
+	WeightC Weight = "C"
+	WeightD Weight = "D"
+)
+
+// Field is a text column contributing to a tsvector column with a weight, defaults to WeightD.
+type Field struct {
+	Column string
+	Weight Weight
+}
+
+// VectorColumn describes a tsvector column generated from text columns of a table and its GIN index.
+//
+// Example usage:
+//
+//	column := search.VectorColumn{
+//	    Table:    "devices",
+//	    Column:   "search_vector",
+//	    Language: search.English,
+//	    Fields:   []search.Field{{Column: "name", Weight: search.WeightA}, {Column: "description", Weight: search.WeightB}},
+//	}
+//	err := column.Ensure(ctx, conn) // or add column.SQL() to a migration
+type VectorColumn struct {
+	Table    string
+	Column   string
+	Language Language
+	Fields   []Field
+}
+
+// validate checks that every identifier of the column can be written into DDL.
+func (v VectorColumn) validate() error {
+	if len(v.Fields) == 0 {
+		return fmt.Errorf("tsvector column %q has no fields", v.Column)
+	}
+	identifiers := []string{v.Table, v.Column, string(v.Language)}
+	for _, f := range v.Fields {
+		identifiers = append(identifiers, f.Column)
+		switch f.Weight {
+		case "", WeightA, WeightB, WeightC, WeightD:
+		default:
+			return fmt.Errorf("invalid tsvector weight %q", f.Weight)
+		}
+	}
+	for _, identifier := range identifiers {
+		if !identifierRg.MatchString(identifier) {
+			return fmt.Errorf("invalid search identifier %q", identifier)
+		}
+	}
+	return nil
+}
+
+// Expression returns the SQL expression computing the tsvector from the fields, NULL fields being treated as empty.
+func (v VectorColumn) Expression() string {
+	parts := make([]string, 0, len(v.Fields))
+	for _, f := range v.Fields {
+		weight := f.Weight
+		if weight == "" {
+			weight = WeightD
+		}
+		parts = append(parts, fmt.Sprintf("setweight(to_tsvector('%s'::regconfig, coalesce(%s, '')), '%s')", v.Language, f.Column, weight))
+	}
+	return strings.Join(parts, " || ")
+}
+
+// IndexName returns the name of the GIN index of the column.
+func (v VectorColumn) IndexName() string {
+	table := v.Table[strings.LastIndex(v.Table, ".")+1:]
+	return fmt.Sprintf("%s_%s_gin_idx", table, v.Column)
+}
+
+// SQL returns the DDL adding the column, kept up to date by Postgres as a stored generated column, and its GIN index.
+// Both statements are idempotent. Changing the fields of an existing column requires dropping it first.
+func (v VectorColumn) SQL() (string, error) {
+	statements, err := v.statements()
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(statements, ";\n") + ";\n", nil
+}
+
+// statements returns the statements of SQL one by one, the connections of db.ConnectDB prepare every statement
+// and Postgres cannot prepare several commands at once.
+func (v VectorColumn) statements() ([]string, error) {
+	if err := v.validate(); err != nil {
+		return nil, err
+	}
+	return []string{
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s tsvector GENERATED ALWAYS AS (%s) STORED", v.Table, v.Column, v.Expression()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s USING GIN (%s)", v.IndexName(), v.Table, v.Column),
+	}, nil
+}
+
+// Ensure adds the column and its GIN index when they do not exist.
+// Postgres rewrites the table when the column is added, prefer a migration with SQL for large tables.
+func (v VectorColumn) Ensure(ctx context.Context, conn *db.DatabaseConnection) error {
+	statements, err := v.statements()
+	if err != nil {
+		return err
+	}
+	return conn.Raw().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return execAll(tx, statements)
+	})
+}
+
+// Rebuild recreates the column and its index from the current fields, for when they change.
+// It runs in a transaction, so the column is never missing for concurrent readers.
+func (v VectorColumn) Rebuild(ctx context.Context, conn *db.DatabaseConnection) error {
+	statements, err := v.statements()
+	if err != nil {
+		return err
+	}
+	drop := fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s", v.Table, v.Column)
+	return conn.Raw().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return execAll(tx, append([]string{drop}, statements...))
+	})
+}
+
+// execAll runs the statements in order, stopping at the first failure.
+func execAll(tx *gorm.DB, statements []string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Searcher returns a Searcher on the column using its language.
+func (v VectorColumn) Searcher() *Searcher {
+	return New(v.Column).WithLanguage(v.Language)
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/shiroyaavish/go-common/errors/db_errors"
+	logger_pkg "github.com/shiroyaavish/go-common/logger"
+	gorm "gorm.io/gorm"
+)
+
+// txCtxKey is the context key holding the transaction started by WithTx.
+type txCtxKey struct{}
+
+// TxOptions configures a transaction started by WithTx.
+// It contains the following fields:
+// - Isolation: the isolation level, sql.LevelDefault uses the database default (read committed)
+// - ReadOnly: starts a read only transaction
+// - MaxRetries: how many times the transaction is retried on serialization failures and deadlocks, defaults to 3, -1 disables retries
+// - RetryBackoff: the initial backoff between retries, doubled after every attempt and jittered, defaults to 50ms
+type TxOptions struct {
+	Isolation    sql.IsolationLevel
+	ReadOnly     bool
+	MaxRetries   int
+	RetryBackoff time.Duration
+}
+
+// TxFunc is the function run inside a transaction. ctx carries the transaction, so that code calling
+// DatabaseConnection.DB(ctx) joins it, and tx is the transaction itself.
+type TxFunc func(ctx context.Context, tx *gorm.DB) error
+
+// WithTx runs fn in a transaction, committing when fn returns nil and rolling back otherwise.
+//
+// The transaction is stored in the context passed to fn, repository code using DB(ctx) picks it up automatically.
+// When ctx already carries a transaction, fn runs in a nested transaction backed by a savepoint, the options are
+// ignored and a failure only rolls back to the savepoint.
+//
+// The outermost transaction is retried with backoff when Postgres reports a serialization failure (SQLSTATE 40001)
+// or a deadlock (SQLSTATE 40P01), so fn must not have side effects outside the database.
+//
+// Example usage:
+//
+//	err := conn.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+//	    if err := tx.Create(&order).Error; err != nil {
+//	        return err
+//	    }
+//	    return inventoryRepository.Reserve(ctx, order.Items) // uses conn.DB(ctx)
+//	}, db.TxOptions{Isolation: sql.LevelSerializable})
+func (d *DatabaseConnection) WithTx(ctx context.Context, fn TxFunc, opts ...TxOptions) error {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx.Transaction(func(nested *gorm.DB) error {
+			return fn(context.WithValue(ctx, txCtxKey{}, nested), nested)
+		})
+	}
+
+	var options TxOptions
+	if len(opts) > 0 {
+		options = opts[0]
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = 3
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = 50 * time.Millisecond
+	}
+	sqlOptions := &sql.TxOptions{Isolation: options.Isolation, ReadOnly: options.ReadOnly}
+
+	backoff := options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(context.WithValue(ctx, txCtxKey{}, tx), tx)
+		}, sqlOptions)
+		if err == nil || !IsRetryableTxError(err) || attempt >= options.MaxRetries {
+			return err
+		}
+
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)))
+		logger_pkg.Warn("transaction failed (attempt %d/%d), retrying in %s: %s", attempt+1, options.MaxRetries+1, wait, err)
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+// DB returns the transaction carried by ctx, or the connection bound to ctx when there is none.
+// Repository code should use it so that it joins the transactions started by WithTx.
+func (d *DatabaseConnection) DB(ctx context.Context) *gorm.DB {
+	if tx, ok := TxFromContext(ctx); ok {
+		return tx
+	}
+	return d.db.WithContext(ctx)
+}
+
+// TxFromContext returns the transaction started by WithTx carried by ctx.
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tx, ok := ctx.Value(txCtxKey{}).(*gorm.DB)
+	return tx, ok && tx != nil
+}
+
+// IsRetryableTxError reports whether err is a Postgres serialization failure (40001) or deadlock (40P01),
+// after which the whole transaction can safely be retried.
+func IsRetryableTxError(err error) bool {
+	return errors.Is(db_errors.Translate(err), db_errors.ErrSerializationFailure)
+}
@@ -28,4 +28,5 @@ var (
 	ErrForbidden          = NewError(fiber.StatusForbidden, "forbidden")
 	ErrConflict           = NewError(fiber.StatusConflict, "conflict")
 	ErrSomethingWentWrong = NewError(fiber.StatusInternalServerError, "something went wrong")
+	ErrServiceUnavailable = NewError(fiber.StatusServiceUnavailable, "service unavailable")
 )
@@ -0,0 +1,237 @@
+package db_errors
+
+import (
+	"context"
+	"database/sql/driver"
+	goErrors "errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"gorm.io/gorm"
+)
+
+// Kind is the category of a translated database error.
+type Kind int
+
+const (
+	KindUnknown Kind = iota
+	KindNotFound
+	KindUniqueViolation
+	KindForeignKeyViolation
+	KindNotNullViolation
+	KindCheckViolation
+	KindSerializationFailure
+	KindStatementTimeout
+	KindConnectionFailure
+)
+
+// String returns a string representation of the Kind.
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindUniqueViolation:
+		return "unique violation"
+	case KindForeignKeyViolation:
+		return "foreign key violation"
+	case KindNotNullViolation:
+		return "not null violation"
+	case KindCheckViolation:
+		return "check violation"
+	case KindSerializationFailure:
+		return "serialization failure"
+	case KindStatementTimeout:
+		return "statement timeout"
+	case KindConnectionFailure:
+		return "connection failure"
+	default:
+		return "unknown"
+	}
+}
+
+// Error is a database error translated by Translate.
+// It carries the Kind, the Postgres SQLSTATE and, when reported by Postgres, the table, column and constraint involved.
+// The original error is kept and returned by Unwrap.
+type Error struct {
+	Kind       Kind
+	Code       string
+	Table      string
+	Column     string
+	Constraint string
+	Err        error
+}
+
+// Error returns a string representation of the Error.
+func (e *Error) Error() string {
+	var builder strings.Builder
+	builder.WriteString("db: ")
+	builder.WriteString(e.Kind.String())
+	if e.Constraint != "" {
+		builder.WriteString(fmt.Sprintf(" (constraint %s)", e.Constraint))
+	} else if e.Column != "" {
+		builder.WriteString(fmt.Sprintf(" (column %s)", e.Column))
+	}
+	if e.Err != nil {
+		builder.WriteString(": ")
+		builder.WriteString(e.Err.Error())
+	}
+	return builder.String()
+}
+
+// Unwrap returns the original error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error matches target: sentinel errors of this package match on their Kind,
+// and ErrAlreadyExists matches unique violations.
+func (e *Error) Is(target error) bool {
+	if target == ErrAlreadyExists {
+		return e.Kind == KindUniqueViolation
+	}
+	t, ok := target.(*Error)
+	return ok && t.Err == nil && t.Kind == e.Kind
+}
+
+// APIError returns the api_errors.Error matching the Kind:
+// - not found: 404
+// - unique violation: 409
+// - foreign key, not null and check violations: 400
+// - serialization failure, statement timeout and connection failure: 503
+// - unknown: 500
+func (e *Error) APIError() *api_errors.Error {
+	switch e.Kind {
+	case KindNotFound:
+		return api_errors.ErrNotFound
+	case KindUniqueViolation:
+		return api_errors.ErrConflict
+	case KindForeignKeyViolation, KindNotNullViolation, KindCheckViolation:
+		return api_errors.ErrInvalidParams
+	case KindSerializationFailure, KindStatementTimeout, KindConnectionFailure:
+		return api_errors.ErrServiceUnavailable
+	default:
+		return api_errors.ErrSomethingWentWrong
+	}
+}
+
+var (
+	// ErrNotFound matches translated gorm.ErrRecordNotFound errors with errors.Is.
+	ErrNotFound = &Error{Kind: KindNotFound}
+	// ErrUniqueViolation matches translated unique violations (23505) with errors.Is.
+	ErrUniqueViolation = &Error{Kind: KindUniqueViolation}
+	// ErrForeignKeyViolation matches translated foreign key violations (23503) with errors.Is.
+	ErrForeignKeyViolation = &Error{Kind: KindForeignKeyViolation}
+	// ErrNotNullViolation matches translated not null violations (23502) with errors.Is.
+	ErrNotNullViolation = &Error{Kind: KindNotNullViolation}
+	// ErrCheckViolation matches translated check violations (23514) with errors.Is.
+	ErrCheckViolation = &Error{Kind: KindCheckViolation}
+	// ErrSerializationFailure matches translated serialization failures (40001) and deadlocks (40P01) with errors.Is.
+	ErrSerializationFailure = &Error{Kind: KindSerializationFailure}
+	// ErrStatementTimeout matches translated statement timeouts and cancellations (57014) and deadlines hit by the driver with errors.Is.
+	ErrStatementTimeout = &Error{Kind: KindStatementTimeout}
+	// ErrConnectionFailure matches translated connection failures (class 08, 57P01-57P03, network errors of the driver) with errors.Is.
+	ErrConnectionFailure = &Error{Kind: KindConnectionFailure}
+)
+
+// Translate converts a pgx, lib/pq or gorm error into an *Error. Errors that are not recognised, nil errors and
+// errors that are already translated are returned unchanged.
+//
+// Example usage:
+//
+//	err := db_errors.Translate(conn.Raw().Create(&user).Error)
+//	if errors.Is(err, db_errors.ErrUniqueViolation) {
+//	    // handle duplicate
+//	}
+func Translate(err error) error {
+	if err == nil {
+		return nil
+	}
+	var translated *Error
+	if goErrors.As(err, &translated) {
+		return err
+	}
+	if goErrors.Is(err, gorm.ErrRecordNotFound) {
+		return &Error{Kind: KindNotFound, Err: err}
+	}
+
+	var pgErr *pgconn.PgError
+	if goErrors.As(err, &pgErr) {
+		if kind := kindOf(pgErr.Code); kind != KindUnknown {
+			return &Error{Kind: kind, Code: pgErr.Code, Table: pgErr.TableName, Column: pgErr.ColumnName, Constraint: pgErr.ConstraintName, Err: err}
+		}
+		return err
+	}
+	var pqErr *pq.Error
+	if goErrors.As(err, &pqErr) {
+		if kind := kindOf(string(pqErr.Code)); kind != KindUnknown {
+			return &Error{Kind: kind, Code: string(pqErr.Code), Table: pqErr.Table, Column: pqErr.Column, Constraint: pqErr.Constraint, Err: err}
+		}
+		return err
+	}
+
+	var connectErr *pgconn.ConnectError
+	if goErrors.As(err, &connectErr) || goErrors.Is(err, driver.ErrBadConn) {
+		return &Error{Kind: KindConnectionFailure, Err: err}
+	}
+	if driverErr, ok := driverErrorOf(err); ok {
+		// context.DeadlineExceeded is a net.Error too, it is checked first
+		var netErr net.Error
+		switch {
+		case goErrors.Is(driverErr, context.DeadlineExceeded):
+			return &Error{Kind: KindStatementTimeout, Err: err}
+		case goErrors.As(driverErr, &netErr):
+			return &Error{Kind: KindConnectionFailure, Err: err}
+		}
+	}
+	return err
+}
+
+// driverErrorOf returns the error of pgconn in the chain of err, pgconn marks its errors with a SafeToRetry method.
+// Deadlines and network errors of other clients (Redis, HTTP, gRPC) are not database errors.
+func driverErrorOf(err error) (error, bool) {
+	var pgconnErr interface {
+		error
+		SafeToRetry() bool
+	}
+	if !goErrors.As(err, &pgconnErr) {
+		return nil, false
+	}
+	return pgconnErr, true
+}
+
+// ToAPIError translates err and returns the matching api_errors.Error, false if err is not a recognised database error.
+func ToAPIError(err error) (*api_errors.Error, bool) {
+	var translated *Error
+	if !goErrors.As(Translate(err), &translated) {
+		return nil, false
+	}
+	return translated.APIError(), true
+}
+
+// kindOf maps a Postgres SQLSTATE to a Kind.
+func kindOf(code string) Kind {
+	switch code {
+	case "23505":
+		return KindUniqueViolation
+	case "23503":
+		return KindForeignKeyViolation
+	case "23502":
+		return KindNotNullViolation
+	case "23514":
+		return KindCheckViolation
+	case "40001", "40P01":
+		return KindSerializationFailure
+	case "57014":
+		return KindStatementTimeout
+	case "57P01", "57P02", "57P03":
+		return KindConnectionFailure
+	}
+	if strings.HasPrefix(code, "08") {
+		return KindConnectionFailure
+	}
+	return KindUnknown
+}
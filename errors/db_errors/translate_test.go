@@ -0,0 +1,98 @@
+package db_errors
+
+import (
+	"context"
+	"database/sql/driver"
+	goErrors "errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// retryableError mimics the pgconn errors wrapping network failures, marked with SafeToRetry.
+type retryableError struct {
+	err error
+}
+
+func (e *retryableError) Error() string     { return "pgconn: " + e.err.Error() }
+func (e *retryableError) Unwrap() error     { return e.err }
+func (e *retryableError) SafeToRetry() bool { return false }
+
+func TestTranslate(t *testing.T) {
+	netErr := &net.OpError{Op: "read", Net: "tcp", Err: goErrors.New("connection reset by peer")}
+	plainErr := goErrors.New("boom")
+
+	tests := []struct {
+		name       string
+		err        error
+		kind       Kind
+		constraint string
+	}{
+		{name: "nil", err: nil},
+		{name: "unknown error", err: plainErr},
+		{name: "record not found", err: fmt.Errorf("find: %w", gorm.ErrRecordNotFound), kind: KindNotFound},
+		{name: "pgx unique violation", err: &pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"}, kind: KindUniqueViolation, constraint: "users_email_key"},
+		{name: "pgx foreign key violation", err: &pgconn.PgError{Code: "23503"}, kind: KindForeignKeyViolation},
+		{name: "pgx not null violation", err: &pgconn.PgError{Code: "23502"}, kind: KindNotNullViolation},
+		{name: "pgx check violation", err: &pgconn.PgError{Code: "23514"}, kind: KindCheckViolation},
+		{name: "pgx deadlock", err: &pgconn.PgError{Code: "40P01"}, kind: KindSerializationFailure},
+		{name: "pgx statement timeout", err: &pgconn.PgError{Code: "57014"}, kind: KindStatementTimeout},
+		{name: "pgx admin shutdown", err: &pgconn.PgError{Code: "57P01"}, kind: KindConnectionFailure},
+		{name: "pgx connection exception", err: &pgconn.PgError{Code: "08006"}, kind: KindConnectionFailure},
+		{name: "pgx syntax error", err: &pgconn.PgError{Code: "42601"}},
+		{name: "pq unique violation", err: &pq.Error{Code: "23505", Constraint: "users_email_key"}, kind: KindUniqueViolation, constraint: "users_email_key"},
+		{name: "pq serialization failure", err: &pq.Error{Code: "40001"}, kind: KindSerializationFailure},
+		{name: "connect error", err: &pgconn.ConnectError{Config: &pgconn.Config{}}, kind: KindConnectionFailure},
+		{name: "bad connection", err: fmt.Errorf("exec: %w", driver.ErrBadConn), kind: KindConnectionFailure},
+		{name: "network error of the driver", err: &retryableError{err: netErr}, kind: KindConnectionFailure},
+		{name: "deadline exceeded of another client", err: fmt.Errorf("wrap: %w", context.DeadlineExceeded)},
+		{name: "deadline exceeded in the driver", err: &retryableError{err: context.DeadlineExceeded}, kind: KindStatementTimeout},
+		{name: "context canceled", err: fmt.Errorf("wrap: %w", context.Canceled)},
+		{name: "network error of another client", err: fmt.Errorf("redis: %w", netErr)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Translate(tt.err)
+			var translated *Error
+			if !goErrors.As(got, &translated) {
+				if tt.kind != KindUnknown {
+					t.Fatalf("expected kind %s, got untranslated %v", tt.kind, got)
+				}
+				if got != tt.err {
+					t.Fatalf("expected the error unchanged, got %v", got)
+				}
+				return
+			}
+			if translated.Kind != tt.kind {
+				t.Fatalf("expected kind %s, got %s", tt.kind, translated.Kind)
+			}
+			if translated.Constraint != tt.constraint {
+				t.Fatalf("expected constraint %q, got %q", tt.constraint, translated.Constraint)
+			}
+			if !goErrors.Is(got, tt.err) {
+				t.Fatalf("the translated error does not wrap the original error")
+			}
+			if Translate(got) != got {
+				t.Fatalf("translating twice changed the error")
+			}
+		})
+	}
+}
+
+func TestToAPIErrorIgnoresOtherClients(t *testing.T) {
+	err := fmt.Errorf("http client: %w", &net.OpError{Op: "dial", Net: "tcp", Err: goErrors.New("i/o timeout")})
+	if apiErr, ok := ToAPIError(err); ok {
+		t.Fatalf("expected no database error, got %v", apiErr)
+	}
+}
+
+func TestToAPIErrorIgnoresOtherDeadlines(t *testing.T) {
+	err := fmt.Errorf("http client: %w", context.DeadlineExceeded)
+	if apiErr, ok := ToAPIError(err); ok {
+		t.Fatalf("expected no database error, got %v", apiErr)
+	}
+}
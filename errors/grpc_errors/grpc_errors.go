@@ -0,0 +1,291 @@
+// Package grpc_errors maps the errors of this library (errors.Error, api_errors.Error and the database errors of
+// db_errors) to gRPC statuses and back, so that services calling each other get the typed errors of the callee.
+package grpc_errors
+
+import (
+	"context"
+	goErrors "errors"
+	"net/http"
+	"strconv"
+
+	"github.com/shiroyaavish/go-common/errors"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/errors/db_errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDomain is the domain of the errdetails.ErrorInfo attached to the statuses built by ToStatus.
+const ErrorDomain = "go-common"
+
+// The reasons of the errdetails.ErrorInfo, telling FromStatus which error type to rebuild.
+const (
+	ReasonAPIError      = "API_ERROR"
+	ReasonError         = "ERROR"
+	ReasonDatabaseError = "DATABASE_ERROR"
+)
+
+// RemoteError is an error rebuilt by FromStatus from the status returned by a remote service.
+// It unwraps to the typed error (api_errors.Error, errors.Error or *db_errors.Error) and keeps the status,
+// so that both errors.As and status.Code work on it.
+type RemoteError struct {
+	status *status.Status
+	err    error
+}
+
+// Error returns the message of the typed error.
+func (e *RemoteError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the typed error.
+func (e *RemoteError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus returns the status the error was rebuilt from.
+func (e *RemoteError) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// CodeFromHTTPStatus returns the gRPC code matching an HTTP status code.
+func CodeFromHTTPStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return codes.OK
+	case statusCode >= 400 && statusCode < 500:
+		return codes.FailedPrecondition
+	default:
+		return codes.Internal
+	}
+}
+
+// HTTPStatusFromCode returns the HTTP status code matching a gRPC code.
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// codeOfKind returns the gRPC code matching the Kind of a database error.
+func codeOfKind(kind db_errors.Kind) codes.Code {
+	switch kind {
+	case db_errors.KindNotFound:
+		return codes.NotFound
+	case db_errors.KindUniqueViolation:
+		return codes.AlreadyExists
+	case db_errors.KindForeignKeyViolation:
+		return codes.FailedPrecondition
+	case db_errors.KindNotNullViolation, db_errors.KindCheckViolation:
+		return codes.InvalidArgument
+	case db_errors.KindSerializationFailure:
+		return codes.Aborted
+	case db_errors.KindStatementTimeout:
+		return codes.DeadlineExceeded
+	case db_errors.KindConnectionFailure:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
+
+// ToStatus converts err into a status:
+// - errors carrying a status (status.Error, RemoteError) keep it
+// - api_errors.Error and errors.Error get the code matching their status code and their message
+// - context cancellations and deadlines get codes.Canceled and codes.DeadlineExceeded
+// - database errors (see db_errors.Translate) get the code matching their Kind and the message of their api_errors.Error
+// - other errors get codes.Internal with a generic message, so that internal details do not leak, and ok is false
+//
+// The typed errors carry an errdetails.ErrorInfo of ErrorDomain from which FromStatus rebuilds them.
+func ToStatus(err error) (st *status.Status, ok bool) {
+	if err == nil {
+		return nil, true
+	}
+	var remoteErr *RemoteError
+	if goErrors.As(err, &remoteErr) {
+		return remoteErr.status, true
+	}
+	if st, isStatus := status.FromError(err); isStatus {
+		return st, true
+	}
+
+	if apiErr, isAPIError := asAPIError(err); isAPIError {
+		return withInfo(CodeFromHTTPStatus(apiErr.StatusCode), apiErr.Message, ReasonAPIError, map[string]string{
+			"status_code": strconv.Itoa(apiErr.StatusCode),
+		}), true
+	}
+	if commonErr, isError := asError(err); isError {
+		return withInfo(CodeFromHTTPStatus(commonErr.StatusCode), commonErr.Message, ReasonError, map[string]string{
+			"status_code": strconv.Itoa(commonErr.StatusCode),
+		}), true
+	}
+	// Checked before Translate, which reports a deadline hit by the driver as a statement timeout
+	switch {
+	case goErrors.Is(err, context.Canceled):
+		return status.New(codes.Canceled, err.Error()), true
+	case goErrors.Is(err, context.DeadlineExceeded):
+		return status.New(codes.DeadlineExceeded, err.Error()), true
+	}
+	var dbErr *db_errors.Error
+	if goErrors.As(db_errors.Translate(err), &dbErr) {
+		return withInfo(codeOfKind(dbErr.Kind), dbErr.APIError().Message, ReasonDatabaseError, map[string]string{
+			"kind":       strconv.Itoa(int(dbErr.Kind)),
+			"code":       dbErr.Code,
+			"table":      dbErr.Table,
+			"column":     dbErr.Column,
+			"constraint": dbErr.Constraint,
+		}), true
+	}
+	return status.New(codes.Internal, api_errors.ErrSomethingWentWrong.Message), false
+}
+
+// FromStatus rebuilds the typed error of an error returned by a gRPC call, errors without a status are returned as is.
+//
+// Statuses built by ToStatus give back the same error type: api_errors.Error, errors.Error or *db_errors.Error.
+// Other statuses give an api_errors.Error with the HTTP status code matching their code, so that the error of a
+// remote service called from an HTTP handler is answered with a sensible status.
+// The result is a *RemoteError, status.Code keeps working on it.
+//
+// Example usage:
+//
+//	_, err := devices.Get(ctx, &pb.GetRequest{Id: id})
+//	err = grpc_errors.FromStatus(err)
+//	var apiErr api_errors.Error
+//	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+//	    return nil, api_errors.ErrNotFound
+//	}
+func FromStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	var remoteErr *RemoteError
+	if goErrors.As(err, &remoteErr) {
+		return err
+	}
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+
+	for _, detail := range st.Details() {
+		info, isInfo := detail.(*errdetails.ErrorInfo)
+		if !isInfo || info.GetDomain() != ErrorDomain {
+			continue
+		}
+		metadata := info.GetMetadata()
+		switch info.GetReason() {
+		case ReasonAPIError:
+			statusCode, _ := strconv.Atoi(metadata["status_code"])
+			return &RemoteError{status: st, err: api_errors.Error{StatusCode: statusCode, Message: st.Message()}}
+		case ReasonError:
+			statusCode, _ := strconv.Atoi(metadata["status_code"])
+			return &RemoteError{status: st, err: errors.Error{StatusCode: statusCode, Message: st.Message()}}
+		case ReasonDatabaseError:
+			kind, _ := strconv.Atoi(metadata["kind"])
+			return &RemoteError{status: st, err: &db_errors.Error{
+				Kind:       db_errors.Kind(kind),
+				Code:       metadata["code"],
+				Table:      metadata["table"],
+				Column:     metadata["column"],
+				Constraint: metadata["constraint"],
+				Err:        goErrors.New(st.Message()),
+			}}
+		}
+	}
+	return &RemoteError{status: st, err: api_errors.Error{StatusCode: HTTPStatusFromCode(st.Code()), Message: st.Message()}}
+}
+
+// withInfo returns a status carrying an errdetails.ErrorInfo of ErrorDomain.
+func withInfo(code codes.Code, message, reason string, metadata map[string]string) *status.Status {
+	st := status.New(code, message)
+	for key, value := range metadata {
+		if value == "" {
+			delete(metadata, key)
+		}
+	}
+	withDetails, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   reason,
+		Domain:   ErrorDomain,
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
+
+// asAPIError returns the api_errors.Error in the chain of err, returned either as a value or as a pointer.
+func asAPIError(err error) (api_errors.Error, bool) {
+	var apiErr api_errors.Error
+	if goErrors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	var apiErrPtr *api_errors.Error
+	if goErrors.As(err, &apiErrPtr) && apiErrPtr != nil {
+		return *apiErrPtr, true
+	}
+	return apiErr, false
+}
+
+// asError returns the errors.Error in the chain of err, returned either as a value or as a pointer.
+func asError(err error) (errors.Error, bool) {
+	var commonErr errors.Error
+	if goErrors.As(err, &commonErr) {
+		return commonErr, true
+	}
+	var commonErrPtr *errors.Error
+	if goErrors.As(err, &commonErrPtr) && commonErrPtr != nil {
+		return *commonErrPtr, true
+	}
+	return commonErr, false
+}
@@ -0,0 +1,44 @@
+package grpc_errors
+
+import (
+	"context"
+	goErrors "errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"google.golang.org/grpc/codes"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		code   codes.Code
+		reason string
+		ok     bool
+	}{
+		{name: "api error", err: api_errors.ErrNotFound, code: codes.NotFound, reason: ReasonAPIError, ok: true},
+		{name: "deadline exceeded", err: fmt.Errorf("handler: %w", context.DeadlineExceeded), code: codes.DeadlineExceeded, ok: true},
+		{name: "canceled", err: fmt.Errorf("handler: %w", context.Canceled), code: codes.Canceled, ok: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, code: codes.AlreadyExists, reason: ReasonDatabaseError, ok: true},
+		{name: "network error of another client", err: &net.OpError{Op: "dial", Net: "tcp", Err: goErrors.New("refused")}, code: codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st, ok := ToStatus(tt.err)
+			if st.Code() != tt.code || ok != tt.ok {
+				t.Fatalf("expected %s (ok %v), got %s (ok %v)", tt.code, tt.ok, st.Code(), ok)
+			}
+			reason := ""
+			if details := st.Details(); len(details) > 0 {
+				reason = details[0].(interface{ GetReason() string }).GetReason()
+			}
+			if reason != tt.reason {
+				t.Fatalf("expected reason %q, got %q", tt.reason, reason)
+			}
+		})
+	}
+}
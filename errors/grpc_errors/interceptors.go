@@ -0,0 +1,80 @@
+package grpc_errors
+
+import (
+	"context"
+
+	"github.com/shiroyaavish/go-common/logger"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor returns the interceptor converting the errors of unary handlers with ToStatus.
+// Errors that cannot be mapped are logged and answered with codes.Internal.
+//
+// Example usage:
+//
+//	server, err := grpc.NewServer(grpc.ServerConfig{
+//	    UnaryInterceptors:  []grpc_lib.UnaryServerInterceptor{grpc_errors.UnaryServerInterceptor(), auth.UnaryServerInterceptor()},
+//	    StreamInterceptors: []grpc_lib.StreamServerInterceptor{grpc_errors.StreamServerInterceptor(), auth.StreamServerInterceptor()},
+//	})
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return nil, serverError(info.FullMethod, err)
+		}
+		return resp, nil
+	}
+}
+
+// StreamServerInterceptor returns the interceptor converting the errors of streaming handlers with ToStatus.
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := handler(srv, ss); err != nil {
+			return serverError(info.FullMethod, err)
+		}
+		return nil
+	}
+}
+
+// serverError converts the error of a handler into a status error, logging the errors that cannot be mapped.
+func serverError(method string, err error) error {
+	st, ok := ToStatus(err)
+	if !ok {
+		logger.Error(err, "grpc handler "+method+" failed")
+	}
+	return st.Err()
+}
+
+// UnaryClientInterceptor returns the interceptor rebuilding the typed errors of unary calls with FromStatus.
+// grpc/client installs it unless Options.DisableErrorMapping is set.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return FromStatus(invoker(ctx, method, req, reply, cc, opts...))
+	}
+}
+
+// StreamClientInterceptor returns the interceptor rebuilding the typed errors of streaming calls with FromStatus.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			return nil, FromStatus(err)
+		}
+		return &clientStream{ClientStream: stream}, nil
+	}
+}
+
+// clientStream is a grpc.ClientStream rebuilding the typed errors of the stream.
+type clientStream struct {
+	grpc.ClientStream
+}
+
+// SendMsg implements grpc.ClientStream.
+func (s *clientStream) SendMsg(m any) error {
+	return FromStatus(s.ClientStream.SendMsg(m))
+}
+
+// RecvMsg implements grpc.ClientStream, io.EOF is returned as is.
+func (s *clientStream) RecvMsg(m any) error {
+	return FromStatus(s.ClientStream.RecvMsg(m))
+}
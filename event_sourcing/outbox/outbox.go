@@ -0,0 +1,163 @@
+// Package outbox implements the transactional outbox pattern on top of db.DatabaseConnection and the rabbit Publisher.
+//
+// Messages published through an Outbox are written to an outbox table in the same transaction as the business data,
+// so they are only sent when the transaction commits. A Relay then forwards the pending rows to RabbitMQ.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/shiroyaavish/go-common/db"
+	"github.com/shiroyaavish/go-common/event_sourcing/rabbit"
+	"gorm.io/gorm"
+)
+
+// DefaultTable is the outbox table used unless WithTable is called.
+const DefaultTable = "outbox_messages"
+
+// tableNameRg restricts the outbox table to a plain (optionally schema qualified) identifier.
+var tableNameRg = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)?$`)
+
+// Message is a row of the outbox table.
+type Message struct {
+	ID            int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Topic         string     `gorm:"column:topic"`
+	Body          []byte     `gorm:"column:body"`
+	Attempts      int        `gorm:"column:attempts"`
+	LastError     string     `gorm:"column:last_error"`
+	CreatedAt     time.Time  `gorm:"column:created_at"`
+	NextAttemptAt time.Time  `gorm:"column:next_attempt_at"`
+	SentAt        *time.Time `gorm:"column:sent_at"`
+}
+
+// GetTopicName implements rabbit.TopicI.
+func (m Message) GetTopicName() string {
+	return m.Topic
+}
+
+// GetBody implements rabbit.TopicI.
+func (m Message) GetBody() []byte {
+	return m.Body
+}
+
+// Outbox writes messages to the outbox table of a database.
+//
+// Example usage:
+//
+//	box := outbox.New(conn)
+//	if err := box.Migrate(ctx); err != nil {
+//	    return err
+//	}
+//	err := conn.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+//	    if err := tx.Create(&device).Error; err != nil {
+//	        return err
+//	    }
+//	    return box.Publish(ctx, &topics.DeviceUpdateTopic{ExternalDeviceID: device.ExternalID})
+//	})
+type Outbox struct {
+	conn  *db.DatabaseConnection
+	table string
+}
+
+// New creates an Outbox storing its messages in DefaultTable of conn.
+func New(conn *db.DatabaseConnection) *Outbox {
+	return &Outbox{
+		conn:  conn,
+		table: DefaultTable,
+	}
+}
+
+// WithTable sets the outbox table, a plain or schema qualified identifier.
+func (o *Outbox) WithTable(table string) *Outbox {
+	o.table = table
+	return o
+}
+
+// Table returns the outbox table.
+func (o *Outbox) Table() string {
+	return o.table
+}
+
+// Schema returns the SQL creating the outbox table and its indexes, to be added to the migrations of a service
+// instead of calling Migrate.
+func (o *Outbox) Schema() string {
+	return strings.Join(o.schemaStatements(), ";\n") + ";\n"
+}
+
+// schemaStatements returns the statements of Schema one by one, the connections of ConnectDB prepare every
+// statement and Postgres cannot prepare several commands at once.
+func (o *Outbox) schemaStatements() []string {
+	index := strings.ReplaceAll(o.table, ".", "_")
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id BIGSERIAL PRIMARY KEY,
+    topic TEXT NOT NULL,
+    body BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at TIMESTAMPTZ
+)`, o.table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_pending_idx ON %s (next_attempt_at, id) WHERE sent_at IS NULL", index, o.table),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_sent_at_idx ON %s (sent_at) WHERE sent_at IS NOT NULL", index, o.table),
+	}
+}
+
+// Migrate creates the outbox table and its indexes when they do not exist.
+func (o *Outbox) Migrate(ctx context.Context) error {
+	if !tableNameRg.MatchString(o.table) {
+		return fmt.Errorf("invalid outbox table %q", o.table)
+	}
+	return o.conn.Raw().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, statement := range o.schemaStatements() {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Publish writes the message to the outbox table. When ctx carries a transaction started by
+// DatabaseConnection.WithTx the row is part of it, and the message is only relayed once the transaction commits.
+// Without a transaction the row is written immediately.
+func (o *Outbox) Publish(ctx context.Context, t rabbit.TopicI) error {
+	if !tableNameRg.MatchString(o.table) {
+		return fmt.Errorf("invalid outbox table %q", o.table)
+	}
+	now := time.Now()
+	message := &Message{
+		Topic:         t.GetTopicName(),
+		Body:          t.GetBody(),
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+	return o.conn.DB(ctx).Table(o.table).Create(message).Error
+}
+
+// Publisher returns a rabbit.PublisherI writing to the outbox within ctx, so that code publishing through the
+// PublisherI interface can join a transaction unchanged.
+func (o *Outbox) Publisher(ctx context.Context) rabbit.PublisherI {
+	return &txPublisher{ctx: ctx, outbox: o}
+}
+
+// txPublisher is a rabbit.PublisherI bound to the context of a transaction.
+type txPublisher struct {
+	ctx    context.Context
+	outbox *Outbox
+}
+
+// Publish implements rabbit.PublisherI.
+func (p *txPublisher) Publish(t rabbit.TopicI) error {
+	return p.outbox.Publish(p.ctx, t)
+}
+
+// Close implements rabbit.PublisherI, there is nothing to close.
+func (p *txPublisher) Close() error {
+	return nil
+}
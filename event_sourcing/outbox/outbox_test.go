@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/db"
+)
+
+// testTopic is a rabbit.TopicI published in the tests.
+type testTopic struct{}
+
+func (testTopic) GetTopicName() string { return "outbox_test_topic" }
+func (testTopic) GetBody() []byte      { return []byte(`{"ok":true}`) }
+
+// connect connects to the Postgres described by the POSTGRES_TEST_* environment variables with ConnectDB,
+// the test is skipped when POSTGRES_TEST_HOST is not set.
+func connect(t *testing.T) *db.DatabaseConnection {
+	t.Helper()
+	host := os.Getenv("POSTGRES_TEST_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_TEST_HOST is not set")
+	}
+	conn, err := db.ConnectDB(config.DatabaseConfig{
+		Host:     host,
+		Port:     envOr("POSTGRES_TEST_PORT", "5432"),
+		User:     envOr("POSTGRES_TEST_USER", "postgres"),
+		Password: os.Getenv("POSTGRES_TEST_PASSWORD"),
+		DBName:   envOr("POSTGRES_TEST_DB", "postgres"),
+		SSLMode:  envOr("POSTGRES_TEST_SSL_MODE", "disable"),
+		LogLevel: "silent",
+	})
+	if err != nil {
+		t.Fatalf("ConnectDB: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Disconnect() })
+	return conn
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func TestMigrateOnConnectDB(t *testing.T) {
+	conn := connect(t)
+	ctx := context.Background()
+	o := New(conn).WithTable(fmt.Sprintf("outbox_test_%d", time.Now().UnixNano()))
+	t.Cleanup(func() { conn.Raw().Exec("DROP TABLE IF EXISTS " + o.Table()) })
+
+	// Migrate runs twice, the second run must find the table and the indexes already there
+	for i := 0; i < 2; i++ {
+		if err := o.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate #%d: %v", i+1, err)
+		}
+	}
+
+	var indexes int64
+	if err := conn.Raw().Raw("SELECT count(*) FROM pg_indexes WHERE tablename = ?", o.Table()).Scan(&indexes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if indexes != 3 {
+		t.Fatalf("expected the primary key and 2 indexes, got %d indexes", indexes)
+	}
+
+	if err := o.Publish(ctx, testTopic{}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	var count int64
+	if err := conn.Raw().Table(o.Table()).Where("topic = ?", testTopic{}.GetTopicName()).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 message, got %d", count)
+	}
+}
+
+func TestSchemaStatements(t *testing.T) {
+	statements := New(nil).WithTable("events.outbox").schemaStatements()
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(statements))
+	}
+	for _, statement := range statements {
+		if strings.Contains(statement, ";") {
+			t.Fatalf("statement %q holds several commands", statement)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shiroyaavish/go-common/db"
+	"github.com/shiroyaavish/go-common/event_sourcing/rabbit"
+	"github.com/shiroyaavish/go-common/logger"
+	gorm "gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxRetryBackoff caps the exponential backoff between attempts of a failing message.
+const maxRetryBackoff = 10 * time.Minute
+
+// PublisherFactory creates the publisher of a topic, used by the Relay to lazily open one publisher per topic.
+type PublisherFactory func(topic string) (rabbit.PublisherI, error)
+
+// RelayOptions configures a Relay.
+// It contains the following fields:
+// - PollInterval: how often pending messages are looked up, defaults to 1 second
+// - BatchSize: how many messages are locked and sent per transaction, defaults to 100
+// - PublishTimeout: how long to wait for the broker to confirm a message, defaults to 10 seconds
+// - MaxAttempts: after how many failed attempts a message is abandoned (kept in the table with its last error), defaults to 10
+// - RetryBackoff: the delay before the first retry of a failed message, doubled after every attempt up to 10 minutes, defaults to 1 second
+// - Retention: how long sent messages are kept before being deleted, defaults to 7 days
+// - CleanupInterval: how often sent messages older than Retention are deleted, defaults to 1 hour
+type RelayOptions struct {
+	PollInterval    time.Duration
+	BatchSize       int
+	PublishTimeout  time.Duration
+	MaxAttempts     int
+	RetryBackoff    time.Duration
+	Retention       time.Duration
+	CleanupInterval time.Duration
+}
+
+// withDefaults fills the zero values of the options with the defaults.
+func (o RelayOptions) withDefaults() RelayOptions {
+	if o.PollInterval <= 0 {
+		o.PollInterval = time.Second
+	}
+	if o.BatchSize <= 0 {
+		o.BatchSize = 100
+	}
+	if o.PublishTimeout <= 0 {
+		o.PublishTimeout = 10 * time.Second
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 10
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = time.Second
+	}
+	if o.Retention <= 0 {
+		o.Retention = 7 * 24 * time.Hour
+	}
+	if o.CleanupInterval <= 0 {
+		o.CleanupInterval = time.Hour
+	}
+	return o
+}
+
+// Relay forwards the pending messages of an Outbox to RabbitMQ.
+//
+// Every instance of a service can run a Relay, only the one holding the Postgres advisory lock of the outbox table
+// (the leader) relays messages, the others take over when the leader's database session ends.
+// Messages are sent in id order with publisher confirms and marked sent once the broker acknowledged them,
+// failed messages are retried with an exponential backoff, which means a failed message can be overtaken by later ones.
+// Delivery is at least once: a message confirmed by the broker may be sent again if marking it sent fails.
+//
+// Example usage:
+//
+//	relay := box.NewRelay(rabbitClient, outbox.RelayOptions{})
+//	relay.Start(ctx)
+//	defer relay.Stop()
+type Relay struct {
+	outbox     *Outbox
+	options    RelayOptions
+	factory    PublisherFactory
+	publishers map[string]rabbit.PublisherI
+
+	lockConn    *sql.Conn
+	lastCleanup time.Time
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRelay creates a Relay publishing the messages of the outbox through a rabbit.Publisher of client per topic.
+func (o *Outbox) NewRelay(client *rabbit.Client, options RelayOptions) *Relay {
+	return o.NewRelayWithFactory(func(topic string) (rabbit.PublisherI, error) {
+		return rabbit.NewPublisher(client, topic)
+	}, options)
+}
+
+// NewRelayWithFactory creates a Relay publishing the messages of the outbox through the publishers created by factory.
+// Publishers implementing rabbit.ConfirmPublisherI are waited on for the broker confirmation.
+func (o *Outbox) NewRelayWithFactory(factory PublisherFactory, options RelayOptions) *Relay {
+	return &Relay{
+		outbox:     o,
+		options:    options.withDefaults(),
+		factory:    factory,
+		publishers: make(map[string]rabbit.PublisherI),
+	}
+}
+
+// Start runs the relay in the background until ctx is done or Stop is called. Calling Start twice has no effect.
+func (r *Relay) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go r.run(ctx, r.stop, r.done)
+}
+
+// Stop stops the relay, waits for the current batch to finish and releases the leadership.
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// run is the relay loop.
+func (r *Relay) run(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+	defer r.release()
+
+	ticker := time.NewTicker(r.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		if r.lead(ctx) {
+			r.relayPending(ctx, stop)
+			r.cleanup(ctx)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead reports whether this instance is the leader, trying to take the advisory lock when it is not
+// and checking that the session holding it is still alive when it is.
+func (r *Relay) lead(ctx context.Context) bool {
+	if r.lockConn != nil {
+		err := r.lockConn.PingContext(ctx)
+		if err == nil {
+			return true
+		}
+		logger.Error(err, "outbox relay lost its database session, giving up leadership")
+		_ = r.lockConn.Close()
+		r.lockConn = nil
+	}
+
+	sqlDB, err := r.outbox.conn.Raw().DB()
+	if err != nil {
+		logger.Error(err, "cannot get outbox database")
+		return false
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		logger.Error(err, "cannot open outbox leader connection")
+		return false
+	}
+	var locked bool
+	lockID := db.AdvisoryLockID("go_common:outbox:" + r.outbox.table)
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", lockID).Scan(&locked); err != nil || !locked {
+		if err != nil {
+			logger.Error(err, "cannot take outbox leader lock")
+		}
+		_ = conn.Close()
+		return false
+	}
+
+	logger.Info("outbox relay of %s is now the leader", r.outbox.table)
+	r.lockConn = conn
+	return true
+}
+
+// release gives up the leadership and closes the publishers.
+func (r *Relay) release() {
+	if r.lockConn != nil {
+		lockID := db.AdvisoryLockID("go_common:outbox:" + r.outbox.table)
+		if _, err := r.lockConn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", lockID); err != nil {
+			logger.Error(err, "cannot release outbox leader lock")
+		}
+		_ = r.lockConn.Close()
+		r.lockConn = nil
+	}
+	for topic, publisher := range r.publishers {
+		if err := publisher.Close(); err != nil {
+			logger.Error(err, "cannot close outbox publisher of "+topic)
+		}
+		delete(r.publishers, topic)
+	}
+}
+
+// relayPending sends batches of pending messages until there are none left.
+func (r *Relay) relayPending(ctx context.Context, stop chan struct{}) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		default:
+		}
+
+		count, err := r.relayBatch(ctx)
+		if err != nil {
+			logger.Error(err, "cannot relay outbox messages")
+			return
+		}
+		if count < r.options.BatchSize {
+			return
+		}
+	}
+}
+
+// relayBatch locks a batch of pending messages, publishes them and records the outcome in the same transaction.
+// It returns the number of messages of the batch.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	count := 0
+	err := r.outbox.conn.WithTx(ctx, func(ctx context.Context, tx *gorm.DB) error {
+		var messages []Message
+		err := tx.Table(r.outbox.table).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND attempts < ? AND next_attempt_at <= ?", r.options.MaxAttempts, time.Now()).
+			Order("id").
+			Limit(r.options.BatchSize).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+		count = len(messages)
+
+		for _, message := range messages {
+			updates := map[string]any{"attempts": gorm.Expr("attempts + 1")}
+			if err := r.publish(ctx, message); err != nil {
+				attempt := message.Attempts + 1
+				if attempt >= r.options.MaxAttempts {
+					logger.Error(err, fmt.Sprintf("outbox message %d abandoned after %d attempts", message.ID, attempt))
+				} else {
+					logger.Warn("outbox message %d failed (attempt %d/%d): %s", message.ID, attempt, r.options.MaxAttempts, err)
+				}
+				updates["last_error"] = err.Error()
+				updates["next_attempt_at"] = time.Now().Add(r.retryBackoff(attempt))
+			} else {
+				updates["sent_at"] = time.Now()
+				updates["last_error"] = ""
+			}
+			if err := tx.Table(r.outbox.table).Where("id = ?", message.ID).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}, db.TxOptions{MaxRetries: -1})
+	return count, err
+}
+
+// publish sends a message through the publisher of its topic, waiting for the broker confirmation when supported.
+func (r *Relay) publish(ctx context.Context, message Message) error {
+	publisher, ok := r.publishers[message.Topic]
+	if !ok {
+		var err error
+		publisher, err = r.factory(message.Topic)
+		if err != nil {
+			return err
+		}
+		r.publishers[message.Topic] = publisher
+	}
+
+	var err error
+	if confirmPublisher, ok := publisher.(rabbit.ConfirmPublisherI); ok {
+		publishCtx, cancel := context.WithTimeout(ctx, r.options.PublishTimeout)
+		err = confirmPublisher.PublishWithConfirm(publishCtx, message)
+		cancel()
+	} else {
+		err = publisher.Publish(message)
+	}
+	if err != nil && !errors.Is(err, rabbit.ErrPublishNacked) {
+		// The channel may be broken, open a new one on the next attempt
+		_ = publisher.Close()
+		delete(r.publishers, message.Topic)
+	}
+	return err
+}
+
+// retryBackoff returns the delay before the next attempt of a message that failed attempt times.
+func (r *Relay) retryBackoff(attempt int) time.Duration {
+	backoff := r.options.RetryBackoff
+	for i := 1; i < attempt && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// cleanup deletes the sent messages older than the retention, at most once per cleanup interval.
+// Abandoned messages are kept for inspection.
+func (r *Relay) cleanup(ctx context.Context) {
+	if time.Since(r.lastCleanup) < r.options.CleanupInterval {
+		return
+	}
+	r.lastCleanup = time.Now()
+
+	result := r.outbox.conn.Raw().WithContext(ctx).Table(r.outbox.table).
+		Where("sent_at IS NOT NULL AND sent_at < ?", time.Now().Add(-r.options.Retention)).
+		Delete(&Message{})
+	if result.Error != nil {
+		logger.Error(result.Error, "cannot clean up outbox messages")
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.Info("deleted %d sent outbox messages", result.RowsAffected)
+	}
+}
@@ -0,0 +1,172 @@
+package rabbit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shiroyaavish/go-common/logger"
+)
+
+// managedChannel is a channel re-created whenever it or the connection of the Client is closed, until it is closed itself.
+type managedChannel struct {
+	client  *Client
+	name    string
+	setup   func(ch *amqp.Channel) error
+	onReady func(ch *amqp.Channel)
+
+	mu       sync.Mutex
+	ch       *amqp.Channel
+	ready    chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// openChannel opens a managed channel prepared by setup, onReady is called after every re-creation.
+func (c *Client) openChannel(name string, setup func(ch *amqp.Channel) error, onReady func(ch *amqp.Channel)) (*managedChannel, error) {
+	m := &managedChannel{
+		client:  c,
+		name:    name,
+		setup:   setup,
+		onReady: onReady,
+		ready:   make(chan struct{}),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	conn := c.Conn()
+	if conn == nil {
+		return nil, ErrNotConnected
+	}
+	ch, err := m.open(conn)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	m.ch = ch
+	close(m.ready)
+	m.mu.Unlock()
+
+	go m.run(ch)
+	return m, nil
+}
+
+// open opens a channel on conn and prepares it.
+func (m *managedChannel) open(conn *amqp.Connection) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if m.setup != nil {
+		if err := m.setup(ch); err != nil {
+			if !ch.IsClosed() {
+				_ = ch.Close()
+			}
+			return nil, err
+		}
+	}
+	return ch, nil
+}
+
+// run re-creates the channel every time it is closed, until the managed channel or the Client is closed.
+func (m *managedChannel) run(ch *amqp.Channel) {
+	defer close(m.done)
+	for {
+		select {
+		case <-m.stop:
+			if !ch.IsClosed() {
+				_ = ch.Close()
+			}
+			return
+		case err, ok := <-ch.NotifyClose(make(chan *amqp.Error, 1)):
+			if ok && err != nil {
+				logger.Error(err, "rabbitmq channel "+m.name+" closed")
+			}
+		}
+		m.invalidate(ch)
+
+		var err error
+		backoff := m.client.options.ReconnectBackoff
+		for {
+			var conn *amqp.Connection
+			if conn, err = m.client.waitConnection(m.stop); err != nil {
+				return
+			}
+			if ch, err = m.open(conn); err == nil {
+				break
+			}
+			logger.Error(err, "cannot re-create rabbitmq channel "+m.name+", retrying in "+backoff.String())
+			select {
+			case <-m.stop:
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(2*backoff, m.client.options.MaxReconnectBackoff)
+		}
+
+		m.mu.Lock()
+		m.ch = ch
+		close(m.ready)
+		m.mu.Unlock()
+		if m.onReady != nil {
+			m.onReady(ch)
+		}
+	}
+}
+
+// invalidate marks ch as unusable when it is still the current channel.
+func (m *managedChannel) invalidate(ch *amqp.Channel) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ch != ch {
+		return
+	}
+	select {
+	case <-m.ready:
+		m.ready = make(chan struct{})
+	default:
+	}
+}
+
+// current returns the channel when it is usable, without waiting.
+func (m *managedChannel) current() (*amqp.Channel, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	select {
+	case <-m.ready:
+		return m.ch, !m.ch.IsClosed()
+	default:
+		return nil, false
+	}
+}
+
+// get returns the channel, waiting until it is usable. It fails with ErrClientClosed once closed, or with the error of ctx.
+func (m *managedChannel) get(ctx context.Context) (*amqp.Channel, error) {
+	for {
+		m.mu.Lock()
+		ch, ready := m.ch, m.ready
+		m.mu.Unlock()
+
+		select {
+		case <-ready:
+			if !ch.IsClosed() {
+				return ch, nil
+			}
+			// Closed but not noticed by run yet
+			m.invalidate(ch)
+		case <-m.done:
+			return nil, ErrClientClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// close closes the channel and stops re-creating it.
+func (m *managedChannel) close() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+	<-m.done
+}
@@ -0,0 +1,131 @@
+package rabbit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// The headers set by a Listener on the messages it retries or dead-letters.
+const (
+	// HeaderAttempts holds how many times the message failed.
+	HeaderAttempts = "x-attempts"
+	// HeaderError holds the error of the last failure.
+	HeaderError = "x-error"
+	// HeaderFailedAt holds the time of the last failure.
+	HeaderFailedAt = "x-failed-at"
+	// HeaderOriginalQueue holds the queue the message was consumed from.
+	HeaderOriginalQueue = "x-original-queue"
+)
+
+// DeadLetterQueueName returns the name of the queue holding the messages of topic that failed too many times.
+func DeadLetterQueueName(topic string) string {
+	return topic + ".dlq"
+}
+
+// retryQueueName returns the name of the queue delaying the retries of topic by delay.
+func retryQueueName(topic string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", topic, delay.Milliseconds())
+}
+
+// declareRetryQueue declares a queue holding messages for delay before sending them back to topic.
+func (c *Client) declareRetryQueue(topic string, delay time.Duration) error {
+	name := retryQueueName(topic, delay)
+	return c.declare("queue "+name, func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(name, true, false, false, false, amqp.Table{
+			"x-message-ttl":             delay.Milliseconds(),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": topic,
+		})
+		return err
+	})
+}
+
+// attemptsOf returns how many times a message already failed, from its HeaderAttempts header.
+func attemptsOf(headers amqp.Table) int {
+	switch attempts := headers[HeaderAttempts].(type) {
+	case int:
+		return attempts
+	case int8:
+		return int(attempts)
+	case int16:
+		return int(attempts)
+	case int32:
+		return int(attempts)
+	case int64:
+		return int(attempts)
+	default:
+		return 0
+	}
+}
+
+// ReplayDeadLetters moves the messages of the dead-letter queue of topic back to topic, with their attempts reset.
+// The error of the last failure is kept in the HeaderError header. Only the messages present when it starts are
+// moved, so that messages failing again are not replayed in a loop. It returns how many messages were moved.
+//
+// Example usage:
+//
+//	replayed, err := rabbit.ReplayDeadLetters(ctx, client, topics.DeviceUpdateTopic)
+//	if err != nil {
+//	    return err
+//	}
+//	logger.Info("replayed %d messages", replayed)
+func ReplayDeadLetters(ctx context.Context, c *Client, topic string) (int, error) {
+	conn := c.Conn()
+	if conn == nil {
+		return 0, ErrNotConnected
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+	if err := ch.Confirm(false); err != nil {
+		return 0, err
+	}
+
+	dlq := DeadLetterQueueName(topic)
+	queue, err := ch.QueueDeclarePassive(dlq, true, false, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for replayed < queue.Messages {
+		if err := ctx.Err(); err != nil {
+			return replayed, err
+		}
+		msg, ok, err := ch.Get(dlq, false)
+		if err != nil {
+			return replayed, err
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		delete(headers, HeaderAttempts)
+		err = publishConfirmed(ctx, ch, "", topic, amqp.Publishing{
+			Headers:      headers,
+			DeliveryMode: amqp.Persistent,
+			Timestamp:    msg.Timestamp,
+			ContentType:  msg.ContentType,
+			MessageId:    msg.MessageId,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			_ = msg.Nack(false, true)
+			return replayed, err
+		}
+		if err := msg.Ack(false); err != nil {
+			return replayed, err
+		}
+		replayed++
+	}
+	return replayed, nil
+}
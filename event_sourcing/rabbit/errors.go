@@ -4,4 +4,13 @@ import "github.com/shiroyaavish/go-common/errors"
 
 var (
 	ErrInvalidConfig = errors.NewError(50001, "invalid config")
+	ErrPublishNacked = errors.NewError(50002, "message not acknowledged by the broker")
+	// ErrNotConnected is returned while the Client is reconnecting, by publishers without a buffer.
+	ErrNotConnected = errors.NewError(50003, "not connected to the broker")
+	// ErrClientClosed is returned once the Client, the Publisher or the Listener is closed.
+	ErrClientClosed = errors.NewError(50004, "client closed")
+	// ErrPublishBufferFull is returned while the Client is reconnecting, when the buffer of the Publisher is full.
+	ErrPublishBufferFull = errors.NewError(50005, "publish buffer full")
+	// ErrInvalidTopology is returned when declaring an exchange, a queue or a binding without a name.
+	ErrInvalidTopology = errors.NewError(50006, "invalid topology")
 )
@@ -1,8 +1,16 @@
 package rabbit
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shiroyaavish/go-common/boundedwg"
 	"github.com/shiroyaavish/go-common/logger"
 )
 
@@ -14,43 +22,292 @@ type ListenerI[B any] interface {
 	AssignHandlerFunction(fn ListenerFunc[B])
 }
 
+// ListenerOptions configures a Listener.
+// It contains the following fields:
+// - Exchange: the exchange the queue is bound to, declared beforehand with a Topology, "" consumes what is published to the queue directly
+// - RoutingKeys: the routing keys (or patterns of a topic exchange) the queue is bound with, defaults to the name of the queue
+// - Concurrency: how many messages are handled at once, defaults to 1, handling the messages one after the other
+// - PartitionKey: when set, the messages with the same key are handled one after the other in the order they were received, e.g. by routing key or by a header
+// - Prefetch: how many unacknowledged messages the broker delivers at once, defaults to 10 or Concurrency when higher
+// - MaxAttempts: how many times a message is handled before it is moved to the dead-letter queue, defaults to 5, 1 disables the retries
+// - RetryDelay: the delay before the first retry of a failed message, doubled after every attempt, defaults to 5 seconds
+// - MaxRetryDelay: the longest delay before a retry, defaults to 5 minutes
+// - PublishTimeout: how long to wait for the broker to confirm a retried or dead-lettered message, defaults to 10 seconds
+type ListenerOptions struct {
+	Exchange       string
+	RoutingKeys    []string
+	Concurrency    int
+	PartitionKey   func(msg amqp.Delivery) string
+	Prefetch       int
+	MaxAttempts    int
+	RetryDelay     time.Duration
+	MaxRetryDelay  time.Duration
+	PublishTimeout time.Duration
+}
+
+// withDefaults returns the options with the defaults applied.
+func (o ListenerOptions) withDefaults() ListenerOptions {
+	if o.Concurrency <= 0 {
+		o.Concurrency = 1
+	}
+	if o.Prefetch <= 0 {
+		o.Prefetch = max(10, o.Concurrency)
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = 5 * time.Second
+	}
+	if o.MaxRetryDelay < o.RetryDelay {
+		o.MaxRetryDelay = max(5*time.Minute, o.RetryDelay)
+	}
+	if o.PublishTimeout <= 0 {
+		o.PublishTimeout = 10 * time.Second
+	}
+	return o
+}
+
+// delayOf returns the delay before retrying a message that failed attempts times.
+func (o ListenerOptions) delayOf(attempts int) time.Duration {
+	delay := o.RetryDelay
+	for i := 1; i < attempts && delay < o.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, o.MaxRetryDelay)
+}
+
+// Listener consumes a queue, decoding the JSON messages into B for its handler function.
+//
+// Messages are acknowledged once handled. A message whose handler fails (or panics) is sent to a retry queue
+// delaying it by ListenerOptions.RetryDelay, doubled after every attempt, and comes back to the queue afterwards.
+// After MaxAttempts failures, or when it cannot be decoded, it is moved to the dead-letter queue (see
+// DeadLetterQueueName) with the error, the attempts and the time in its headers. ReplayDeadLetters moves them back.
+//
+// Up to ListenerOptions.Concurrency messages are handled at once, each decoded into its own B. With a
+// PartitionKey, the messages sharing a key are handled by the same worker in order; a retried message comes back
+// after the messages received meanwhile. Close stops receiving messages and waits for the ones being handled.
+//
+// Example usage:
+//
+//	listener, err := rabbit.NewListenerWithOptions[topics.DeviceUpdate](client, topics.DeviceUpdateTopic, rabbit.ListenerOptions{
+//	    Concurrency: 8,
+//	    PartitionKey: func(msg amqp.Delivery) string {
+//	        device, _ := msg.Headers["device-id"].(string)
+//	        return device
+//	    },
+//	    MaxAttempts: 3,
+//	    RetryDelay:  10 * time.Second,
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	listener.AssignHandlerFunction(func(body *topics.DeviceUpdate) error {
+//	    return devices.Apply(ctx, body)
+//	})
+//	go listener.ListenAsync()
+//	defer listener.Close()
 type Listener[B any] struct {
-	ch       *amqp.Channel
+	ch       *managedChannel
 	cl       *Client
 	topic    string
+	options  ListenerOptions
 	actionFn ListenerFunc[B]
-	closer   chan struct{}
-	body     *B
+
+	pool       boundedwg.BoundedWaitGroup
+	partitions []chan delivery
+	inflight   sync.WaitGroup
+
+	mu        sync.Mutex
+	consumer  *amqp.Channel
+	tag       string
+	closing   bool
+	ctx       context.Context
+	cancel    context.CancelFunc
+	startOnce sync.Once
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// delivery is a message received on the channel ch.
+type delivery struct {
+	ch  *amqp.Channel
+	msg amqp.Delivery
 }
 
 func (l *Listener[B]) AssignHandlerFunction(fn ListenerFunc[B]) {
 	l.actionFn = fn
 }
 
+// ListenAsync consumes the queue until Close is called, it blocks until then.
+// When the channel or the connection is lost, consuming resumes once the channel is re-created.
 func (l *Listener[B]) ListenAsync() {
-	consumerChannel, err := l.ch.Consume(l.topic, "", true, false, false, false, nil)
-	if err != nil {
-		panic(err)
-	}
+	l.startOnce.Do(func() {
+		go l.consume()
+	})
+	<-l.done
+}
 
-	go func() {
-		for msg := range consumerChannel {
-			// Call the action function with the message body
-			err := json.Unmarshal(msg.Body, &l.body)
-			if err != nil {
-				logger.Error(err, "Cannot Unmarshal inside Listener")
+// consume consumes the queue, subscribing again every time the channel is re-created.
+func (l *Listener[B]) consume() {
+	defer close(l.done)
+	backoff := l.cl.options.ReconnectBackoff
+	for {
+		ch, err := l.ch.get(l.ctx)
+		if err != nil {
+			return
+		}
+		tag := l.topic + "-" + uuid.NewString()
+		deliveries, err := ch.Consume(l.topic, tag, false, false, false, false, nil)
+		if err != nil {
+			logger.Error(err, "cannot consume "+l.topic+", retrying in "+backoff.String())
+			select {
+			case <-l.ctx.Done():
+				return
+			case <-time.After(backoff):
 			}
+			continue
+		}
 
-			err = l.actionFn(l.body)
-			if err != nil {
-				logger.Error(err, "Cannot Run Action function inside Listener")
-			}
+		l.mu.Lock()
+		l.consumer, l.tag = ch, tag
+		closing := l.closing
+		l.mu.Unlock()
+		if closing {
+			_ = ch.Cancel(tag, false)
+		}
+
+		for msg := range deliveries {
+			l.dispatch(ch, msg)
+		}
+		l.pool.Wait()
+		l.inflight.Wait()
+
+		l.mu.Lock()
+		l.consumer = nil
+		closing = l.closing
+		l.mu.Unlock()
+		if closing || l.ctx.Err() != nil {
+			return
+		}
+		logger.Warn("consumer of %s stopped, resuming once the channel is re-created", l.topic)
+	}
+}
+
+// dispatch hands a message over to the worker of its partition, or to the pool when the messages are not partitioned.
+// It blocks while all the workers are busy.
+func (l *Listener[B]) dispatch(ch *amqp.Channel, msg amqp.Delivery) {
+	if l.options.PartitionKey == nil {
+		l.pool.Add(1)
+		go func() {
+			defer l.pool.Done()
+			l.handle(ch, msg)
+		}()
+		return
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(l.options.PartitionKey(msg)))
+	l.inflight.Add(1)
+	l.partitions[hash.Sum32()%uint32(len(l.partitions))] <- delivery{ch: ch, msg: msg}
+}
+
+// work handles the messages of a partition one after the other.
+func (l *Listener[B]) work(partition <-chan delivery) {
+	for d := range partition {
+		l.handle(d.ch, d.msg)
+		l.inflight.Done()
+	}
+}
+
+// handle decodes and handles a message, then acknowledges it or hands it over to fail.
+func (l *Listener[B]) handle(ch *amqp.Channel, msg amqp.Delivery) {
+	attempts := attemptsOf(msg.Headers) + 1
+	body := new(B)
+	if err := json.Unmarshal(msg.Body, body); err != nil {
+		logger.Error(err, "Cannot Unmarshal inside Listener")
+		l.fail(ch, msg, attempts, err, false)
+		return
+	}
+
+	if err := l.run(body); err != nil {
+		logger.Error(err, "Cannot Run Action function inside Listener")
+		l.fail(ch, msg, attempts, err, attempts < l.options.MaxAttempts)
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		logger.Error(err, "cannot acknowledge message of "+l.topic+", it will be delivered again")
+	}
+}
+
+// run calls the handler function, turning a panic into an error.
+func (l *Listener[B]) run(body *B) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic in listener of %s: %v", l.topic, r)
 		}
 	}()
+	return l.actionFn(body)
+}
+
+// fail sends a failed message to its retry queue, or to the dead-letter queue when it is not retried, then acknowledges it.
+// When the broker does not confirm the copy, the message is requeued instead.
+func (l *Listener[B]) fail(ch *amqp.Channel, msg amqp.Delivery, attempts int, cause error, retry bool) {
+	queue := DeadLetterQueueName(l.topic)
+	if retry {
+		queue = retryQueueName(l.topic, l.options.delayOf(attempts))
+	}
+
+	headers := amqp.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[HeaderAttempts] = int32(attempts)
+	headers[HeaderError] = cause.Error()
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[HeaderOriginalQueue] = l.topic
 
-	<-l.closer
+	ctx, cancel := context.WithTimeout(context.Background(), l.options.PublishTimeout)
+	defer cancel()
+	err := publishConfirmed(ctx, ch, "", queue, amqp.Publishing{
+		Headers:      headers,
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    msg.Timestamp,
+		ContentType:  msg.ContentType,
+		MessageId:    msg.MessageId,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		logger.Error(err, "cannot move failed message of "+l.topic+" to "+queue+", requeuing it")
+		_ = msg.Nack(false, true)
+		return
+	}
+	if err := msg.Ack(false); err != nil {
+		logger.Error(err, "cannot acknowledge failed message of "+l.topic)
+	}
 }
 
+// Close stops receiving messages, waits for the messages received to be handled, then closes the channel of the listener.
 func (l *Listener[B]) Close() {
-	l.closer <- struct{}{}
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		l.closing = true
+		consumer, tag := l.consumer, l.tag
+		l.mu.Unlock()
+		// Cancelling the consumer closes the deliveries once the messages already sent by the broker are received,
+		// they are handled and acknowledged before consume returns. Without a consumer there is nothing to drain.
+		if consumer == nil || consumer.Cancel(tag, false) != nil {
+			l.cancel()
+		}
+		l.startOnce.Do(func() {
+			close(l.done)
+		})
+		<-l.done
+
+		l.cancel()
+		l.ch.close()
+		for _, partition := range l.partitions {
+			close(partition)
+		}
+	})
 }
@@ -1,37 +1,154 @@
 package rabbit
 
 import (
-	amqp "github.com/rabbitmq/amqp091-go"
+	"context"
+	"errors"
 	"sync"
 	"time"
-)
 
-var once sync.Once
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shiroyaavish/go-common/logger"
+)
 
 type PublisherI interface {
 	Publish(t TopicI) error
 	Close() error
 }
 
+// ConfirmPublisherI is a PublisherI able to wait for the broker to confirm a message.
+type ConfirmPublisherI interface {
+	PublisherI
+	PublishWithConfirm(ctx context.Context, t TopicI) error
+}
+
+// Publisher publishes topics to a queue through the default exchange, or to an exchange (see NewExchangePublisher).
+// A topic is published with its topic name as routing key, or with the one of RoutedTopicI on an exchange,
+// and with the headers of HeadersTopicI.
 type Publisher struct {
-	ch *amqp.Channel
-	cl *Client
+	ch       *managedChannel
+	cl       *Client
+	exchange string
+
+	mu     sync.Mutex
+	buffer []pendingPublishing
 }
 
-// Publish publishes the data to the queue
-func (p *Publisher) Publish(t TopicI) error {
-	// Publish with Context
-	return p.ch.Publish("", t.GetTopicName(), false, false, amqp.Publishing{
+// pendingPublishing is a message buffered while disconnected.
+type pendingPublishing struct {
+	key string
+	msg amqp.Publishing
+}
+
+// publishing returns the message sent for t.
+func publishing(t TopicI) amqp.Publishing {
+	msg := amqp.Publishing{
 		DeliveryMode: amqp.Persistent,
 		Timestamp:    time.Now(),
 		ContentType:  "text/plain",
 		Body:         t.GetBody(),
-	})
+	}
+	if withHeaders, ok := t.(HeadersTopicI); ok {
+		msg.Headers = withHeaders.GetHeaders()
+	}
+	return msg
+}
+
+// Publish publishes the data to the queue, or to the exchange of the publisher.
+// While the Client is reconnecting the message is buffered when ClientOptions.PublishBufferSize allows it and sent
+// once reconnected, otherwise Publish fails with ErrNotConnected (ErrPublishBufferFull once the buffer is full).
+func (p *Publisher) Publish(t TopicI) error {
+	pending := pendingPublishing{key: p.routingKey(t), msg: publishing(t)}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ch, ok := p.ch.current(); ok && p.flushLocked(ch) == nil {
+		err := ch.Publish(p.exchange, pending.key, false, false, pending.msg)
+		if !errors.Is(err, amqp.ErrClosed) {
+			return err
+		}
+	}
+
+	size := p.cl.options.PublishBufferSize
+	switch {
+	case size <= 0:
+		return ErrNotConnected
+	case len(p.buffer) >= size:
+		return ErrPublishBufferFull
+	}
+	p.buffer = append(p.buffer, pending)
+	return nil
 }
 
+// flush sends the buffered messages on a re-created channel.
+func (p *Publisher) flush(ch *amqp.Channel) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.flushLocked(ch); err != nil {
+		logger.Error(err, "cannot send the buffered rabbitmq messages")
+	}
+}
+
+// flushLocked sends the buffered messages in order, stopping at the first failure.
+func (p *Publisher) flushLocked(ch *amqp.Channel) error {
+	for len(p.buffer) > 0 {
+		pending := p.buffer[0]
+		if err := ch.Publish(p.exchange, pending.key, false, false, pending.msg); err != nil {
+			return err
+		}
+		p.buffer[0] = pendingPublishing{}
+		p.buffer = p.buffer[1:]
+	}
+	p.buffer = nil
+	return nil
+}
+
+// PublishWithConfirm publishes the data to the queue, or to the exchange of the publisher, and waits until the broker confirms it.
+// While the Client is reconnecting it waits for the connection to come back.
+// It returns ErrPublishNacked when the broker rejects the message, or the context error when ctx is done first.
+func (p *Publisher) PublishWithConfirm(ctx context.Context, t TopicI) error {
+	ch, err := p.ch.get(ctx)
+	if err != nil {
+		return err
+	}
+	return publishConfirmed(ctx, ch, p.exchange, p.routingKey(t), publishing(t))
+}
+
+// routingKey returns the routing key t is published with: its topic name, the queue of the default exchange,
+// or the routing key of RoutedTopicI when publishing to an exchange.
+func (p *Publisher) routingKey(t TopicI) string {
+	if routed, ok := t.(RoutedTopicI); ok && p.exchange != "" {
+		return routed.GetRoutingKey()
+	}
+	return t.GetTopicName()
+}
+
+// publishConfirmed publishes msg on a channel in confirm mode and waits until the broker confirms it.
+func publishConfirmed(ctx context.Context, ch *amqp.Channel, exchange, key string, msg amqp.Publishing) error {
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		return err
+	}
+	ack, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !ack {
+		return ErrPublishNacked
+	}
+	return nil
+}
+
+// Close closes the channel of the publisher, the messages still buffered are dropped.
 func (p *Publisher) Close() error {
-	if p.ch != nil {
-		return p.ch.Close()
+	if p.ch == nil {
+		return nil
+	}
+	p.ch.close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buffer) > 0 {
+		logger.Warn("dropping %d buffered rabbitmq messages", len(p.buffer))
+		p.buffer = nil
 	}
 	return nil
 }
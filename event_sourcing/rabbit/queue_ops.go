@@ -1,53 +1,66 @@
 package rabbit
 
 import (
+	"context"
 	"errors"
+
 	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/shiroyaavish/go-common/boundedwg"
 	"github.com/shiroyaavish/go-common/logger"
 )
 
-func (c *Client) createQueue(topic string) (*amqp.Channel, error) {
-	ch, err := c.Connection.Channel()
-	if err != nil {
-		return nil, err
-	}
+// declareQueue declares a durable queue, declared again after every reconnection.
+// A queue already declared by a Topology keeps its arguments.
+func (c *Client) declareQueue(topic string) error {
+	return c.declareQueueWith(Queue{Name: topic})
+}
 
-	_, err = ch.QueueDeclare(topic, true, false, false, false, nil)
-	if err != nil {
+// NewPublisher creates a Publisher of the queue topic, declaring the queue.
+func NewPublisher(c *Client, topic string) (PublisherI, error) {
+	if err := c.declareQueue(topic); err != nil {
 		return nil, err
 	}
-
-	return ch, nil
+	return newPublisher(c, "publisher "+topic, "")
 }
 
-func NewPublisher(c *Client, topic string) (PublisherI, error) {
-	// Declare new Publisher
-	p := &Publisher{
-		ch: nil,
-		cl: c,
+// NewExchangePublisher creates a Publisher of the exchange, declared beforehand with a Topology.
+//
+// Example usage:
+//
+//	publisher, err := rabbit.NewExchangePublisher(client, "devices")
+//	if err != nil {
+//	    return err
+//	}
+//	defer publisher.Close()
+//	err = publisher.Publish(&topics.DeviceUpdateTopic{ExternalDeviceID: id}) // routed with GetRoutingKey when implemented
+func NewExchangePublisher(c *Client, exchange string) (PublisherI, error) {
+	if exchange == "" {
+		return nil, ErrInvalidTopology
 	}
+	return newPublisher(c, "publisher "+exchange, exchange)
+}
 
-	confirms := make(chan amqp.Confirmation)
-
-	ch, err := p.cl.createQueue(topic)
-	if err != nil {
-		panic(err)
+// newPublisher creates a Publisher on a channel in confirm mode, publishing to exchange.
+func newPublisher(c *Client, name, exchange string) (*Publisher, error) {
+	p := &Publisher{
+		cl:       c,
+		exchange: exchange,
 	}
-	p.ch = ch
 
-	p.ch.NotifyPublish(confirms)
-
-	go func() {
-		for confirm := range confirms {
-			if confirm.Ack {
-				logger.Info("Message published successfully")
-			} else {
-				logger.Error(errors.New("failed to publish message"))
+	var err error
+	p.ch, err = c.openChannel(name, func(ch *amqp.Channel) error {
+		confirms := ch.NotifyPublish(make(chan amqp.Confirmation, 1))
+		go func() {
+			for confirm := range confirms {
+				if confirm.Ack {
+					logger.Info("Message published successfully")
+				} else {
+					logger.Error(errors.New("failed to publish message"))
+				}
 			}
-		}
-	}()
-
-	err = p.ch.Confirm(false)
+		}()
+		return ch.Confirm(false)
+	}, p.flush)
 	if err != nil {
 		return nil, err
 	}
@@ -55,21 +68,65 @@ func NewPublisher(c *Client, topic string) (PublisherI, error) {
 	return p, nil
 }
 
+// NewListener creates a Listener of the queue topic with the default ListenerOptions.
 func NewListener[B any](c *Client, topic string) (ListenerI[B], error) {
-	l := &Listener[B]{
-		ch:       nil,
-		cl:       c,
-		topic:    topic,
-		actionFn: nil,
-		closer:   make(chan struct{}),
+	return NewListenerWithOptions[B](c, topic, ListenerOptions{})
+}
+
+// NewListenerWithOptions creates a Listener of the queue topic, declaring the queue, its retry queues and its dead-letter queue,
+// and binding the queue to ListenerOptions.Exchange when set.
+func NewListenerWithOptions[B any](c *Client, topic string, options ListenerOptions) (ListenerI[B], error) {
+	options = options.withDefaults()
+	if err := c.declareQueue(topic); err != nil {
+		return nil, err
+	}
+	if options.Exchange != "" {
+		routingKeys := options.RoutingKeys
+		if len(routingKeys) == 0 {
+			routingKeys = []string{topic}
+		}
+		for _, routingKey := range routingKeys {
+			if err := c.declareBinding(Binding{Exchange: options.Exchange, Queue: topic, RoutingKey: routingKey}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := c.declareQueue(DeadLetterQueueName(topic)); err != nil {
+		return nil, err
+	}
+	for attempts := 1; attempts < options.MaxAttempts; attempts++ {
+		if err := c.declareRetryQueue(topic, options.delayOf(attempts)); err != nil {
+			return nil, err
+		}
 	}
 
-	ch, err := l.cl.createQueue(l.topic)
+	ch, err := c.openChannel("listener "+topic, func(ch *amqp.Channel) error {
+		if err := ch.Qos(options.Prefetch, 0, false); err != nil {
+			return err
+		}
+		return ch.Confirm(false)
+	}, nil)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	// Assign the channel for the first time
-	l.ch = ch
 
+	ctx, cancel := context.WithCancel(context.Background())
+	l := &Listener[B]{
+		ch:      ch,
+		cl:      c,
+		topic:   topic,
+		options: options,
+		pool:    boundedwg.New(options.Concurrency),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	if options.PartitionKey != nil {
+		l.partitions = make([]chan delivery, options.Concurrency)
+		for i := range l.partitions {
+			l.partitions[i] = make(chan delivery, options.Prefetch)
+			go l.work(l.partitions[i])
+		}
+	}
 	return l, nil
 }
@@ -2,17 +2,113 @@ package rabbit
 
 import (
 	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
 	amqp "github.com/rabbitmq/amqp091-go"
 	"github.com/shiroyaavish/go-common/config"
-	"net/url"
+	"github.com/shiroyaavish/go-common/logger"
 )
 
+// ConnectionState is the state of the connection of a Client.
+type ConnectionState int
+
+const (
+	// StateConnected is the state of a Client connected to the broker.
+	StateConnected ConnectionState = iota
+	// StateDisconnected is the state of a Client that lost its connection and is reconnecting.
+	StateDisconnected
+	// StateClosed is the state of a Client closed with Close.
+	StateClosed
+)
+
+// String returns a string representation of the ConnectionState.
+func (s ConnectionState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	case StateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ClientOptions configures the connection supervision of a Client.
+// It contains the following fields:
+// - ReconnectBackoff: the delay before the first reconnection attempt, doubled after every failure, defaults to 1 second
+// - MaxReconnectBackoff: the longest delay between two reconnection attempts, defaults to 30 seconds
+// - PublishBufferSize: how many messages each Publisher keeps while disconnected, sent once reconnected, 0 fails Publish with ErrNotConnected
+// - OnStateChange: called on every change of the connection state, with the error that closed the connection when disconnected
+// - Topology: the exchanges, queues and bindings declared once connected, see Topology
+type ClientOptions struct {
+	ReconnectBackoff    time.Duration
+	MaxReconnectBackoff time.Duration
+	PublishBufferSize   int
+	OnStateChange       func(state ConnectionState, err error)
+	Topology            Topology
+}
+
+// withDefaults returns the options with the defaults applied.
+func (o ClientOptions) withDefaults() ClientOptions {
+	if o.ReconnectBackoff <= 0 {
+		o.ReconnectBackoff = time.Second
+	}
+	if o.MaxReconnectBackoff < o.ReconnectBackoff {
+		o.MaxReconnectBackoff = 30 * time.Second
+	}
+	return o
+}
+
+// Client is a connection to RabbitMQ supervised in the background.
+//
+// When the connection is lost the Client reconnects with an exponential backoff, declares the queues again,
+// and the channels of its publishers and listeners are re-created: listeners resume consuming and publishers
+// send the messages buffered meanwhile (see ClientOptions.PublishBufferSize).
+//
+// Example usage:
+//
+//	client, err := rabbit.NewClientWithOptions(&cfg.RabbitMQ, rabbit.ClientOptions{
+//	    PublishBufferSize: 1000,
+//	    OnStateChange: func(state rabbit.ConnectionState, err error) {
+//	        logger.Warn("rabbitmq is %s", state)
+//	    },
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	defer client.Close()
 type Client struct {
+	// Connection is the current connection, replaced on every reconnection. Use Conn to read it safely.
 	Connection *amqp.Connection
+
+	uri          string
+	options      ClientOptions
+	mu           sync.Mutex
+	state        ConnectionState
+	connected    chan struct{}
+	declarations []declaration
+	closed       chan struct{}
+	done         chan struct{}
+}
+
+// declaration declares a part of the topology on a channel, it is run again after every reconnection.
+type declaration struct {
+	key     string
+	declare func(ch *amqp.Channel) error
 }
 
+// NewClient connects to RabbitMQ with the default ClientOptions.
 func NewClient(cfg *config.RabbitMQConfig) (*Client, error) {
-	c := new(Client)
+	return NewClientWithOptions(cfg, ClientOptions{})
+}
+
+// NewClientWithOptions connects to RabbitMQ, the first connection attempt is not retried.
+func NewClientWithOptions(cfg *config.RabbitMQConfig, options ClientOptions) (*Client, error) {
+	c := &Client{options: options}
 	err := c.Connect(cfg)
 	if err != nil {
 		return nil, err
@@ -20,23 +116,237 @@ func NewClient(cfg *config.RabbitMQConfig) (*Client, error) {
 	return c, nil
 }
 
+// Connect dials RabbitMQ, declares the topology of the ClientOptions and supervises the connection until Close is called.
 func (c *Client) Connect(cfg *config.RabbitMQConfig) error {
 	if cfg == nil {
 		return ErrInvalidConfig
 	}
-	uri := fmt.Sprintf("amqps://%s:%s@%s:%s", url.PathEscape(cfg.Username), url.PathEscape(cfg.Password), cfg.Host, url.PathEscape(cfg.Port))
-	// new code to encode the uri
-	var err error
-	c.Connection, err = amqp.Dial(uri)
+	c.uri = fmt.Sprintf("amqps://%s:%s@%s:%s", url.PathEscape(cfg.Username), url.PathEscape(cfg.Password), cfg.Host, url.PathEscape(cfg.Port))
+	c.options = c.options.withDefaults()
+	conn, err := amqp.Dial(c.uri)
 	if err != nil {
 		return err
 	}
+
+	c.mu.Lock()
+	c.Connection = conn
+	c.state = StateConnected
+	c.connected = make(chan struct{})
+	close(c.connected)
+	c.closed = make(chan struct{})
+	c.done = make(chan struct{})
+	c.mu.Unlock()
+
+	go c.supervise(conn)
+	if err := c.DeclareTopology(c.options.Topology); err != nil {
+		_ = c.Close()
+		return err
+	}
 	return nil
 }
 
+// Conn returns the current connection, nil while disconnected.
+func (c *Client) Conn() *amqp.Connection {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != StateConnected {
+		return nil
+	}
+	return c.Connection
+}
+
+// State returns the current state of the connection.
+func (c *Client) State() ConnectionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// Close closes the connection and stops reconnecting, the publishers and listeners of the Client stop working.
 func (c *Client) Close() error {
-	if c.Connection != nil {
-		return c.Connection.Close()
+	c.mu.Lock()
+	if c.closed == nil || c.state == StateClosed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.state = StateClosed
+	close(c.closed)
+	conn := c.Connection
+	c.mu.Unlock()
+
+	var err error
+	if conn != nil && !conn.IsClosed() {
+		err = conn.Close()
 	}
+	<-c.done
+	c.notify(StateClosed, nil)
+	return err
+}
+
+// supervise waits for the connection to be lost and reconnects, until the Client is closed.
+func (c *Client) supervise(conn *amqp.Connection) {
+	defer close(c.done)
+	for {
+		closeErr, ok := <-conn.NotifyClose(make(chan *amqp.Error, 1))
+		select {
+		case <-c.closed:
+			return
+		default:
+		}
+
+		var err error = amqp.ErrClosed
+		if ok && closeErr != nil {
+			err = closeErr
+		}
+		logger.Error(err, "rabbitmq connection lost, reconnecting")
+		c.mu.Lock()
+		c.state = StateDisconnected
+		c.connected = make(chan struct{})
+		c.mu.Unlock()
+		c.notify(StateDisconnected, err)
+
+		conn = c.reconnect()
+		if conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials until it succeeds or the Client is closed, returning nil when closed.
+func (c *Client) reconnect() *amqp.Connection {
+	backoff := c.options.ReconnectBackoff
+	for {
+		select {
+		case <-c.closed:
+			return nil
+		case <-time.After(backoff):
+		}
+
+		conn, err := amqp.Dial(c.uri)
+		if err != nil {
+			backoff = min(2*backoff, c.options.MaxReconnectBackoff)
+			logger.Error(err, "cannot reconnect to rabbitmq, retrying in "+backoff.String())
+			continue
+		}
+		c.redeclare(conn)
+
+		c.mu.Lock()
+		if c.state == StateClosed {
+			c.mu.Unlock()
+			_ = conn.Close()
+			return nil
+		}
+		c.Connection = conn
+		c.state = StateConnected
+		close(c.connected)
+		c.mu.Unlock()
+		logger.Info("reconnected to rabbitmq")
+		c.notify(StateConnected, nil)
+		return conn
+	}
+}
+
+// notify calls the OnStateChange callback.
+func (c *Client) notify(state ConnectionState, err error) {
+	if c.options.OnStateChange != nil {
+		c.options.OnStateChange(state, err)
+	}
+}
+
+// waitConnection returns the connection once connected, or ErrClientClosed when the Client is closed first
+// or ErrNotConnected when stop is closed first.
+func (c *Client) waitConnection(stop <-chan struct{}) (*amqp.Connection, error) {
+	for {
+		c.mu.Lock()
+		state, conn, connected, closed := c.state, c.Connection, c.connected, c.closed
+		c.mu.Unlock()
+		switch {
+		case closed == nil || state == StateClosed:
+			return nil, ErrClientClosed
+		case state == StateConnected && !conn.IsClosed():
+			return conn, nil
+		}
+
+		select {
+		case <-connected:
+			if state == StateConnected {
+				// The connection was closed but the supervisor has not noticed yet
+				select {
+				case <-time.After(c.options.ReconnectBackoff):
+				case <-stop:
+					return nil, ErrNotConnected
+				}
+			}
+		case <-closed:
+			return nil, ErrClientClosed
+		case <-stop:
+			return nil, ErrNotConnected
+		}
+	}
+}
+
+// declare runs fn on a channel and records it, so that it is run again after every reconnection.
+// Declarations with the same key are run and recorded once, the first one wins.
+func (c *Client) declare(key string, fn func(ch *amqp.Channel) error) error {
+	if c.declared(key) {
+		return nil
+	}
+	conn := c.Conn()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+	if err := fn(ch); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.declarations {
+		if d.key == key {
+			return nil
+		}
+	}
+	c.declarations = append(c.declarations, declaration{key: key, declare: fn})
 	return nil
 }
+
+// declared reports whether a declaration with the key is recorded.
+func (c *Client) declared(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.declarations {
+		if d.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// redeclare runs the recorded declarations on a new connection, a failed declaration is logged and skipped.
+func (c *Client) redeclare(conn *amqp.Connection) {
+	c.mu.Lock()
+	declarations := append([]declaration(nil), c.declarations...)
+	c.mu.Unlock()
+
+	var ch *amqp.Channel
+	for _, d := range declarations {
+		if ch == nil || ch.IsClosed() {
+			var err error
+			if ch, err = conn.Channel(); err != nil {
+				logger.Error(err, "cannot open a channel to declare the rabbitmq topology")
+				return
+			}
+		}
+		if err := d.declare(ch); err != nil {
+			logger.Error(err, "cannot declare "+d.key)
+		}
+	}
+	if ch != nil && !ch.IsClosed() {
+		_ = ch.Close()
+	}
+}
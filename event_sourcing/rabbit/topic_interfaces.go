@@ -1,6 +1,21 @@
 package rabbit
 
+import amqp "github.com/rabbitmq/amqp091-go"
+
 type TopicI interface {
 	GetTopicName() string
 	GetBody() []byte
 }
+
+// RoutedTopicI is a TopicI published to an exchange with a routing key other than its topic name,
+// e.g. device.<id>.updated on a topic exchange. Publishers of a queue keep sending it to its topic name.
+type RoutedTopicI interface {
+	TopicI
+	GetRoutingKey() string
+}
+
+// HeadersTopicI is a TopicI published with headers, e.g. matched by the bindings of a headers exchange.
+type HeadersTopicI interface {
+	TopicI
+	GetHeaders() amqp.Table
+}
@@ -0,0 +1,190 @@
+package rabbit
+
+import (
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ExchangeKind is the routing algorithm of an exchange.
+type ExchangeKind string
+
+const (
+	// ExchangeDirect routes a message to the queues bound with its routing key.
+	ExchangeDirect ExchangeKind = amqp.ExchangeDirect
+	// ExchangeTopic routes a message to the queues bound with a pattern matching its routing key, e.g. device.*.updated.
+	ExchangeTopic ExchangeKind = amqp.ExchangeTopic
+	// ExchangeFanout routes a message to all the bound queues.
+	ExchangeFanout ExchangeKind = amqp.ExchangeFanout
+	// ExchangeHeaders routes a message to the queues bound with arguments matching its headers.
+	ExchangeHeaders ExchangeKind = amqp.ExchangeHeaders
+)
+
+// QueueType is the type of a queue.
+type QueueType string
+
+const (
+	// QueueClassic is the default type of queue.
+	QueueClassic QueueType = amqp.QueueTypeClassic
+	// QueueQuorum is a replicated queue, for messages that must survive the loss of a broker node.
+	QueueQuorum QueueType = amqp.QueueTypeQuorum
+)
+
+// Exchange is a durable exchange of a Topology.
+// It contains the following fields:
+// - Name: the name of the exchange
+// - Kind: the routing algorithm of the exchange, defaults to ExchangeDirect
+// - AutoDelete: deletes the exchange once its last binding is removed
+// - Internal: only accepts messages from other exchanges
+// - Args: extra arguments, e.g. alternate-exchange
+type Exchange struct {
+	Name       string
+	Kind       ExchangeKind
+	AutoDelete bool
+	Internal   bool
+	Args       amqp.Table
+}
+
+// Queue is a durable queue of a Topology.
+// It contains the following fields:
+// - Name: the name of the queue
+// - Type: the type of the queue, defaults to QueueClassic
+// - MessageTTL: how long a message stays in the queue before it expires, 0 keeps it until consumed
+// - MaxLength: how many messages the queue holds before dropping (or dead-lettering) the oldest ones, 0 is unbounded
+// - MaxLengthBytes: how many bytes of messages the queue holds before dropping (or dead-lettering) the oldest ones, 0 is unbounded
+// - DeadLetterExchange: the exchange receiving the rejected, expired and dropped messages, "" is the default exchange when DeadLetterRoutingKey is set
+// - DeadLetterRoutingKey: the routing key of the dead-lettered messages, defaults to their own routing key
+// - Args: extra arguments, applied after the fields above
+type Queue struct {
+	Name                 string
+	Type                 QueueType
+	MessageTTL           time.Duration
+	MaxLength            int
+	MaxLengthBytes       int
+	DeadLetterExchange   string
+	DeadLetterRoutingKey string
+	Args                 amqp.Table
+}
+
+// arguments returns the arguments declaring the queue.
+func (q Queue) arguments() amqp.Table {
+	args := amqp.Table{}
+	if q.Type != "" {
+		args[amqp.QueueTypeArg] = string(q.Type)
+	}
+	if q.MessageTTL > 0 {
+		args[amqp.QueueMessageTTLArg] = q.MessageTTL.Milliseconds()
+	}
+	if q.MaxLength > 0 {
+		args[amqp.QueueMaxLenArg] = q.MaxLength
+	}
+	if q.MaxLengthBytes > 0 {
+		args[amqp.QueueMaxLenBytesArg] = q.MaxLengthBytes
+	}
+	if q.DeadLetterExchange != "" || q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-exchange"] = q.DeadLetterExchange
+	}
+	if q.DeadLetterRoutingKey != "" {
+		args["x-dead-letter-routing-key"] = q.DeadLetterRoutingKey
+	}
+	for key, value := range q.Args {
+		args[key] = value
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return args
+}
+
+// Binding routes the messages of an exchange to a queue.
+// It contains the following fields:
+// - Exchange: the name of the exchange
+// - Queue: the name of the queue
+// - RoutingKey: the routing key, or the pattern of a topic exchange, ignored by fanout and headers exchanges
+// - Args: the headers matched by a headers exchange, with x-match set to all or any
+type Binding struct {
+	Exchange   string
+	Queue      string
+	RoutingKey string
+	Args       amqp.Table
+}
+
+// Topology is the set of exchanges, queues and bindings a service relies on.
+//
+// Declaring it is idempotent: what already exists with the same settings is left as is, so every instance
+// declares it at startup. It is declared again after every reconnection. Declaring an exchange or a queue that
+// exists with other settings fails with a PRECONDITION_FAILED error from the broker.
+//
+// Example usage:
+//
+//	client, err := rabbit.NewClientWithOptions(&cfg.RabbitMQ, rabbit.ClientOptions{
+//	    Topology: rabbit.Topology{
+//	        Exchanges: []rabbit.Exchange{{Name: "devices", Kind: rabbit.ExchangeTopic}},
+//	        Queues: []rabbit.Queue{{Name: "notifications.device_updates", Type: rabbit.QueueQuorum, MessageTTL: 24 * time.Hour}},
+//	        Bindings: []rabbit.Binding{{Exchange: "devices", Queue: "notifications.device_updates", RoutingKey: "device.*.updated"}},
+//	    },
+//	})
+type Topology struct {
+	Exchanges []Exchange
+	Queues    []Queue
+	Bindings  []Binding
+}
+
+// DeclareTopology declares the exchanges, then the queues, then the bindings of t, they are declared again after
+// every reconnection. It stops at the first failure.
+func (c *Client) DeclareTopology(t Topology) error {
+	for _, exchange := range t.Exchanges {
+		if err := c.declareExchange(exchange); err != nil {
+			return err
+		}
+	}
+	for _, queue := range t.Queues {
+		if err := c.declareQueueWith(queue); err != nil {
+			return err
+		}
+	}
+	for _, binding := range t.Bindings {
+		if err := c.declareBinding(binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// declareExchange declares a durable exchange.
+func (c *Client) declareExchange(e Exchange) error {
+	if e.Name == "" {
+		return ErrInvalidTopology
+	}
+	kind := e.Kind
+	if kind == "" {
+		kind = ExchangeDirect
+	}
+	return c.declare("exchange "+e.Name, func(ch *amqp.Channel) error {
+		return ch.ExchangeDeclare(e.Name, string(kind), true, e.AutoDelete, e.Internal, false, e.Args)
+	})
+}
+
+// declareQueueWith declares a durable queue with the arguments of q.
+func (c *Client) declareQueueWith(q Queue) error {
+	if q.Name == "" {
+		return ErrInvalidTopology
+	}
+	args := q.arguments()
+	return c.declare("queue "+q.Name, func(ch *amqp.Channel) error {
+		_, err := ch.QueueDeclare(q.Name, true, false, false, false, args)
+		return err
+	})
+}
+
+// declareBinding binds a queue to an exchange.
+func (c *Client) declareBinding(b Binding) error {
+	if b.Exchange == "" || b.Queue == "" {
+		return ErrInvalidTopology
+	}
+	key := fmt.Sprintf("binding %s %s %s %v", b.Exchange, b.Queue, b.RoutingKey, b.Args)
+	return c.declare(key, func(ch *amqp.Channel) error {
+		return ch.QueueBind(b.Queue, b.RoutingKey, b.Exchange, false, b.Args)
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"time"
 )
 
+// BaseModel is the base of the topic models, kept for compatibility. New models should embed db.BaseModel,
+// which adds the audit columns filled by the db model plugin.
 type BaseModel struct {
 	ID        *uuid.UUID      `gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
 	UpdatedAt *time.Time      `json:"updated_at"`
@@ -0,0 +1,8 @@
+package flags
+
+import "github.com/shiroyaavish/go-common/errors"
+
+var (
+	// ErrUnknownFlag is returned when a flag that has not been registered is updated.
+	ErrUnknownFlag = errors.NewError(404, "unknown flag")
+)
@@ -0,0 +1,359 @@
+// Package flags provides feature flags declared in code with a default value, loaded from the config layer,
+// overridable at runtime from Redis and targetable per common.Project, per access ID or by percentage rollout.
+//
+// Precedence of the flag state is default < config (`FLAG_<NAME>` variables) < Redis override, or runtime override
+// set with Registry.Set when no Redis is configured.
+// A flag evaluates to true for a caller when it is enabled globally, or when the caller's access ID or project
+// is targeted, or when the caller falls inside the rollout percentage (stable hash of flag name and caller).
+//
+// Example usage:
+//
+//	registry := flags.NewRegistry()
+//	newCheckout := registry.Register("new_checkout", false, "Serves the new checkout flow")
+//	if err := registry.LoadConfig(config.DefaultLoader()); err != nil {
+//	    logger.Fatal(err)
+//	}
+//	registry.WithRedis(redisClient).StartSync(ctx, 30*time.Second)
+//
+//	router := http_server.NewRouter(http_server.RouterConfig{Flags: registry})
+//	registry.Mount(router.Group("/admin/flags"))
+//
+//	// in a handler
+//	if data.IsFlagEnabled("new_checkout") { ... }
+//	// or outside of a request
+//	if newCheckout.Enabled(flags.Target{ProjectID: common.FDS}) { ... }
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/redis"
+	"github.com/shiroyaavish/go-common/utils"
+)
+
+// Source represents where the current state of a flag comes from.
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceConfig  Source = "config"
+	SourceRedis   Source = "redis"
+	// SourceRuntime is the source of the overrides set with Registry.Set on a Registry without Redis,
+	// they are local to the process and kept until the next Set.
+	SourceRuntime Source = "runtime"
+)
+
+// Override is the targeting of a flag, as set through the config layer, Redis or the admin handler.
+// It contains the following fields:
+// - Enabled: enables the flag for every caller
+// - Projects: projects for which the flag is enabled
+// - AccessIDs: access IDs (users or services) for which the flag is enabled
+// - Percentage: percentage (0-100) of callers for which the flag is enabled
+type Override struct {
+	Enabled    bool             `json:"enabled"`
+	Projects   []common.Project `json:"projects,omitempty"`
+	AccessIDs  []uuid.UUID      `json:"access_ids,omitempty"`
+	Percentage int              `json:"percentage" validate:"min=0,max=100"`
+}
+
+// State is the declaration and current targeting of a flag.
+type State struct {
+	Override
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Default     bool      `json:"default"`
+	Source      Source    `json:"source"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// base and baseSource are the targeting of the default and config layers, restored when the Redis override is deleted
+	base       Override
+	baseSource Source
+}
+
+// Target identifies the caller a flag is evaluated for.
+type Target struct {
+	ProjectID common.Project
+	AccessId  *uuid.UUID
+}
+
+// Flag is a handle to a registered flag.
+type Flag struct {
+	name     string
+	registry *Registry
+}
+
+// Name returns the name of the flag.
+func (f *Flag) Name() string {
+	return f.name
+}
+
+// Enabled reports whether the flag is enabled for the given target.
+func (f *Flag) Enabled(target Target) bool {
+	return f.registry.IsEnabled(f.name, target)
+}
+
+// Registry holds the declared flags and their current state.
+// The zero value is not usable, create a Registry using NewRegistry.
+type Registry struct {
+	mu    sync.RWMutex
+	flags map[string]*State
+	order []string
+
+	redisClient *redis.RedisClient
+	redisKey    string
+	stopSync    chan struct{}
+}
+
+var defaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		flags:    make(map[string]*State),
+		redisKey: DefaultRedisKey,
+	}
+}
+
+// Default returns the package level Registry used by Register and IsEnabled.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register declares a flag on the default Registry.
+func Register(name string, defaultValue bool, description string) *Flag {
+	return defaultRegistry.Register(name, defaultValue, description)
+}
+
+// IsEnabled evaluates a flag of the default Registry.
+func IsEnabled(name string, target Target) bool {
+	return defaultRegistry.IsEnabled(name, target)
+}
+
+// Register declares a flag with its default value. Registering an existing name returns the existing flag.
+func (r *Registry) Register(name string, defaultValue bool, description string) *Flag {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.flags[name]; !ok {
+		r.flags[name] = &State{
+			Override:    Override{Enabled: defaultValue},
+			Name:        name,
+			Description: description,
+			Default:     defaultValue,
+			Source:      SourceDefault,
+			UpdatedAt:   time.Now(),
+			base:        Override{Enabled: defaultValue},
+			baseSource:  SourceDefault,
+		}
+		r.order = append(r.order, name)
+	}
+	return &Flag{name: name, registry: r}
+}
+
+// LoadConfig applies the config layer to every registered flag using the given loader (nil uses config.DefaultLoader()).
+// Flags overridden in Redis or at runtime keep their override, the config layer applies once a Redis override is deleted.
+// For a flag named "new_checkout" the following variables are read:
+// - FLAG_NEW_CHECKOUT: true/false, enables the flag for everyone
+// - FLAG_NEW_CHECKOUT_PROJECTS: project IDs separated by "," as sent in the Project-ID header
+// - FLAG_NEW_CHECKOUT_ACCESS_IDS: access IDs separated by ","
+// - FLAG_NEW_CHECKOUT_PERCENTAGE: rollout percentage
+func (r *Registry) LoadConfig(loader *config.Loader) error {
+	if loader == nil {
+		loader = config.DefaultLoader()
+	}
+	loader.Reload()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, name := range r.order {
+		state := r.flags[name]
+		envName := EnvName(name)
+		override := state.base
+		found := false
+
+		if value := loader.Get(envName); value != "" {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s: %w", envName, err)
+			}
+			override.Enabled = enabled
+			found = true
+		}
+		if value := loader.Get(envName + "_PROJECTS"); value != "" {
+			override.Projects = nil
+			for _, project := range splitList(value) {
+				override.Projects = append(override.Projects, common.ParseProjectFromString(project))
+			}
+			found = true
+		}
+		if value := loader.Get(envName + "_ACCESS_IDS"); value != "" {
+			override.AccessIDs = nil
+			for _, id := range splitList(value) {
+				accessId, err := uuid.Parse(id)
+				if err != nil {
+					return fmt.Errorf("invalid value for %s_ACCESS_IDS: %w", envName, err)
+				}
+				override.AccessIDs = append(override.AccessIDs, accessId)
+			}
+			found = true
+		}
+		if value := loader.Get(envName + "_PERCENTAGE"); value != "" {
+			percentage, err := strconv.Atoi(value)
+			if err != nil || percentage < 0 || percentage > 100 {
+				return fmt.Errorf("invalid value for %s_PERCENTAGE: %s", envName, value)
+			}
+			override.Percentage = percentage
+			found = true
+		}
+
+		if !found {
+			continue
+		}
+		state.base = override
+		state.baseSource = SourceConfig
+		if state.Source != SourceRedis && state.Source != SourceRuntime {
+			state.Override = override
+			state.Source = SourceConfig
+			state.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+// IsEnabled reports whether the named flag is enabled for the given target. Unknown flags are disabled.
+func (r *Registry) IsEnabled(name string, target Target) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.flags[name]
+	if !ok {
+		return false
+	}
+	return state.Override.evaluate(name, target)
+}
+
+// IsEnabledFor reports whether the named flag is enabled for the given project and access ID.
+// It allows the Registry to be used as http_server.FlagEvaluator.
+func (r *Registry) IsEnabledFor(name string, projectID common.Project, accessId *uuid.UUID) bool {
+	return r.IsEnabled(name, Target{ProjectID: projectID, AccessId: accessId})
+}
+
+// Get returns the state of the named flag.
+func (r *Registry) Get(name string) (State, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	state, ok := r.flags[name]
+	if !ok {
+		return State{}, false
+	}
+	return *state, true
+}
+
+// List returns the state of every registered flag in registration order.
+func (r *Registry) List() []State {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	states := make([]State, 0, len(r.order))
+	for _, name := range r.order {
+		states = append(states, *r.flags[name])
+	}
+	return states
+}
+
+// apply replaces the targeting of the named flag, it returns false if the flag is not registered.
+func (r *Registry) apply(name string, override Override, source Source) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state, ok := r.flags[name]
+	if !ok {
+		return false
+	}
+	state.Override = override
+	state.Source = source
+	state.UpdatedAt = time.Now()
+	return true
+}
+
+// restoreDeleted restores the default or config targeting of the flags overridden in Redis whose override is no
+// longer in overrides.
+func (r *Registry) restoreDeleted(overrides map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, state := range r.flags {
+		if _, ok := overrides[name]; ok || state.Source != SourceRedis {
+			continue
+		}
+		state.Override = state.base
+		state.Source = state.baseSource
+		state.UpdatedAt = time.Now()
+	}
+}
+
+// evaluate evaluates the targeting of a flag for a caller.
+func (o Override) evaluate(name string, target Target) bool {
+	if o.Enabled {
+		return true
+	}
+	if target.AccessId != nil && utils.IsInArray(*target.AccessId, o.AccessIDs) {
+		return true
+	}
+	if target.ProjectID != common.UnknownProject && utils.IsInArray(target.ProjectID, o.Projects) {
+		return true
+	}
+	if o.Percentage <= 0 {
+		return false
+	}
+	if o.Percentage >= 100 {
+		return true
+	}
+
+	var key string
+	switch {
+	case target.AccessId != nil:
+		key = target.AccessId.String()
+	case target.ProjectID != common.UnknownProject:
+		key = target.ProjectID.String()
+	default:
+		return false
+	}
+	return bucket(name, key) < uint32(o.Percentage)
+}
+
+// bucket returns the stable rollout bucket (0-99) of a caller for a flag.
+// Hashing the flag name with the key keeps the buckets of different flags independent.
+func bucket(name, key string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	_, _ = h.Write([]byte{':'})
+	_, _ = h.Write([]byte(key))
+	return h.Sum32() % 100
+}
+
+// EnvName returns the config variable of a flag, e.g. "new-checkout" becomes "FLAG_NEW_CHECKOUT".
+func EnvName(name string) string {
+	return "FLAG_" + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name))
+}
+
+// splitList splits a "," separated list and drops empty entries.
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
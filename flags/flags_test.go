@@ -0,0 +1,62 @@
+package flags
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiroyaavish/go-common/config"
+)
+
+func TestSetWithoutRedisSurvivesLoadConfig(t *testing.T) {
+	t.Setenv("FLAG_NEW_CHECKOUT", "false")
+	t.Setenv("FLAG_NEW_CHECKOUT_PERCENTAGE", "10")
+	registry := NewRegistry()
+	registry.Register("new_checkout", false, "")
+	if err := registry.LoadConfig(config.NewLoader(nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Set(context.Background(), "new_checkout", Override{Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	state, _ := registry.Get("new_checkout")
+	if state.Source != SourceRuntime || !state.Enabled {
+		t.Fatalf("expected the runtime override, got %+v", state)
+	}
+
+	if err := registry.LoadConfig(config.NewLoader(nil)); err != nil {
+		t.Fatal(err)
+	}
+	state, _ = registry.Get("new_checkout")
+	if state.Source != SourceRuntime || !state.Enabled || state.Percentage != 0 {
+		t.Fatalf("expected the runtime override to survive LoadConfig, got %+v", state)
+	}
+}
+
+func TestDeletedRedisOverrideRestoresConfig(t *testing.T) {
+	t.Setenv("FLAG_NEW_CHECKOUT_PERCENTAGE", "30")
+	registry := NewRegistry()
+	registry.Register("new_checkout", false, "")
+	registry.Register("dark_mode", true, "")
+	if err := registry.LoadConfig(config.NewLoader(nil)); err != nil {
+		t.Fatal(err)
+	}
+	registry.apply("new_checkout", Override{Enabled: true}, SourceRedis)
+	registry.apply("dark_mode", Override{}, SourceRedis)
+
+	registry.restoreDeleted(map[string]string{"dark_mode": "{}"})
+	state, _ := registry.Get("new_checkout")
+	if state.Source != SourceConfig || state.Enabled || state.Percentage != 30 {
+		t.Fatalf("expected the config state, got %+v", state)
+	}
+	state, _ = registry.Get("dark_mode")
+	if state.Source != SourceRedis {
+		t.Fatalf("expected the Redis override to be kept, got %+v", state)
+	}
+
+	registry.restoreDeleted(nil)
+	state, _ = registry.Get("dark_mode")
+	if state.Source != SourceDefault || !state.Enabled {
+		t.Fatalf("expected the default state, got %+v", state)
+	}
+}
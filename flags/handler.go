@@ -0,0 +1,75 @@
+package flags
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/http_server"
+)
+
+// flagParams are the path parameters of the admin handlers.
+type flagParams struct {
+	Name string `params:"name" validate:"required"`
+}
+
+// ListHandler returns an admin handler listing the state of every registered flag.
+func (r *Registry) ListHandler() fiber.Handler {
+	return http_server.NewHandler[any, any, any]().
+		SetAccessLevel(common.AccessLevelAdmin).
+		SetHandler(func(data http_server.RequestData[any, any, any]) (any, error) {
+			return r.List(), nil
+		}).
+		Build()
+}
+
+// GetHandler returns an admin handler returning the state of the flag in the `name` path parameter.
+func (r *Registry) GetHandler() fiber.Handler {
+	return http_server.NewHandler[flagParams, any, any]().
+		SetAccessLevel(common.AccessLevelAdmin).
+		ParseParam().
+		SetHandler(func(data http_server.RequestData[flagParams, any, any]) (any, error) {
+			state, ok := r.Get(data.Params.Name)
+			if !ok {
+				return nil, *api_errors.ErrNotFound
+			}
+			return state, nil
+		}).
+		Build()
+}
+
+// UpdateHandler returns an admin handler replacing the targeting of the flag in the `name` path parameter
+// with the Override in the body.
+func (r *Registry) UpdateHandler() fiber.Handler {
+	return http_server.NewHandler[flagParams, any, Override]().
+		SetAccessLevel(common.AccessLevelAdmin).
+		ParseParam().
+		ParseBody().
+		SetHandler(func(data http_server.RequestData[flagParams, any, Override]) (any, error) {
+			err := r.Set(data.Context.UserContext(), data.Params.Name, *data.Body)
+			if errors.Is(err, ErrUnknownFlag) {
+				return nil, *api_errors.ErrNotFound
+			}
+			if err != nil {
+				return nil, err
+			}
+			state, _ := r.Get(data.Params.Name)
+			return state, nil
+		}).
+		Build()
+}
+
+// Mount registers the admin handlers on the given router:
+// - GET / lists the flags
+// - GET /:name returns a flag
+// - PUT /:name replaces the targeting of a flag
+//
+// Example usage:
+//
+//	registry.Mount(router.Group("/admin/flags"))
+func (r *Registry) Mount(router fiber.Router) {
+	router.Get("/", r.ListHandler())
+	router.Get("/:name", r.GetHandler())
+	router.Put("/:name", r.UpdateHandler())
+}
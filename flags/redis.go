@@ -0,0 +1,118 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/shiroyaavish/go-common/logger"
+	"github.com/shiroyaavish/go-common/redis"
+)
+
+// DefaultRedisKey is the Redis hash holding the runtime overrides, one JSON encoded Override per flag name.
+const DefaultRedisKey = "go_common:flags"
+
+// WithRedis enables runtime overrides stored in Redis.
+func (r *Registry) WithRedis(client *redis.RedisClient) *Registry {
+	r.redisClient = client
+	return r
+}
+
+// WithRedisKey sets the Redis hash holding the overrides, defaults to DefaultRedisKey.
+// Use a key per service when several services share a Redis database.
+func (r *Registry) WithRedisKey(key string) *Registry {
+	r.redisKey = key
+	return r
+}
+
+// Refresh loads the overrides from Redis and applies them on top of the config layer.
+// Overrides of flags that are not registered are ignored, flags whose override was deleted get back their config state.
+func (r *Registry) Refresh(ctx context.Context) error {
+	if r.redisClient == nil {
+		return nil
+	}
+
+	values, err := r.redisClient.Raw().HGetAll(ctx, r.redisKey).Result()
+	if err != nil {
+		return err
+	}
+
+	for name, value := range values {
+		var override Override
+		if err := json.Unmarshal([]byte(value), &override); err != nil {
+			logger.Error(err, "invalid flag override in redis: "+name)
+			continue
+		}
+		r.apply(name, override, SourceRedis)
+	}
+	r.restoreDeleted(values)
+	return nil
+}
+
+// Set stores the override of the named flag in Redis (when configured) and applies it locally.
+// Without Redis the override has the SourceRuntime source and takes precedence over the config layer.
+// It returns ErrUnknownFlag if the flag is not registered.
+func (r *Registry) Set(ctx context.Context, name string, override Override) error {
+	if _, ok := r.Get(name); !ok {
+		return ErrUnknownFlag
+	}
+
+	source := SourceRuntime
+	if r.redisClient != nil {
+		value, err := json.Marshal(override)
+		if err != nil {
+			return err
+		}
+		if err := r.redisClient.Raw().HSet(ctx, r.redisKey, name, value).Err(); err != nil {
+			return err
+		}
+		source = SourceRedis
+	}
+
+	r.apply(name, override, source)
+	return nil
+}
+
+// StartSync refreshes the overrides from Redis immediately and then every interval until ctx is done or StopSync is called.
+func (r *Registry) StartSync(ctx context.Context, interval time.Duration) {
+	if err := r.Refresh(ctx); err != nil {
+		logger.Error(err, "cannot refresh feature flags")
+	}
+
+	r.mu.Lock()
+	if r.stopSync != nil {
+		r.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	r.stopSync = stop
+	r.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Refresh(ctx); err != nil {
+					logger.Error(err, "cannot refresh feature flags")
+				}
+			}
+		}
+	}()
+}
+
+// StopSync stops the periodic refresh started by StartSync.
+func (r *Registry) StopSync() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopSync != nil {
+		close(r.stopSync)
+		r.stopSync = nil
+	}
+}
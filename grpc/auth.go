@@ -0,0 +1,145 @@
+package grpc
+
+import (
+	"context"
+
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// AuthConfig configures an Authenticator.
+// It contains the following fields:
+// - Methods: the access level of each full method name (`/package.Service/Method`)
+// - Permissions: the permissions a caller must all hold to call a method, by full method name, checked for user and admin levels
+// - Default: the access level of the methods missing from Methods, nil rejects them with codes.PermissionDenied
+// - Settings: the configuration holding the API keys and JWT secrets, defaults to config.Default()
+type AuthConfig struct {
+	Methods     map[string]common.AccessLevel
+	Permissions map[string][]string
+	Default     *common.AccessLevel
+	Settings    *config.Settings
+}
+
+// Authenticator checks the credentials of incoming RPCs with the same rules as the HTTP handlers
+// (see common.AccessLevel.CheckAccess), reading the api-key, x-api-key and authorization metadata.
+//
+// The access ID, the permissions and the project (project-id metadata) of the caller are put into the context
+// of the handler, see common.AccessIdFromContext, common.AccessPermissionsFromContext and common.ProjectFromContext.
+// Missing or invalid credentials fail with codes.Unauthenticated. Valid credentials of an insufficient level,
+// missing permissions and unregistered methods fail with codes.PermissionDenied.
+//
+// Example usage:
+//
+//	auth := grpc.NewAuthenticator(grpc.AuthConfig{
+//	    Methods: map[string]common.AccessLevel{
+//	        "/devices.DeviceService/Get":    common.AccessLevelUser,
+//	        "/devices.DeviceService/Delete": common.AccessLevelAdmin,
+//	        "/devices.DeviceService/Sync":   common.AccessLevelService,
+//	    },
+//	    Permissions: map[string][]string{"/devices.DeviceService/Delete": {"devices:delete"}},
+//	})
+//	server, err := grpc.NewServer(grpc.ServerConfig{
+//	    UnaryInterceptors:  []grpc_lib.UnaryServerInterceptor{auth.UnaryServerInterceptor(), limiter.UnaryServerInterceptor()},
+//	    StreamInterceptors: []grpc_lib.StreamServerInterceptor{auth.StreamServerInterceptor(), limiter.StreamServerInterceptor()},
+//	})
+type Authenticator struct {
+	config AuthConfig
+}
+
+// NewAuthenticator creates an Authenticator from the config.
+func NewAuthenticator(cfg AuthConfig) *Authenticator {
+	return &Authenticator{
+		config: cfg,
+	}
+}
+
+// UnaryServerInterceptor returns the interceptor authenticating unary RPCs.
+func (a *Authenticator) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := a.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor authenticating streaming RPCs.
+func (a *Authenticator) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// levelOf returns the access level of the method, false when it is not registered and there is no default.
+func (a *Authenticator) levelOf(method string) (common.AccessLevel, bool) {
+	if level, ok := a.config.Methods[method]; ok {
+		return level, true
+	}
+	if a.config.Default != nil {
+		return *a.config.Default, true
+	}
+	return common.AccessLevelPublic, false
+}
+
+// authenticate checks the credentials of the caller of method, returning the context carrying its identity.
+func (a *Authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	level, ok := a.levelOf(method)
+	if !ok {
+		return nil, status.Errorf(codes.PermissionDenied, "method %s is not registered", method)
+	}
+
+	headers := metadataHeaders(ctx)
+	accessId, permissions, accessErr := level.CheckAccessFrom(a.config.Settings, headers)
+	if accessErr != nil {
+		// A valid user token calling an admin method is authenticated but not allowed
+		if level == common.AccessLevelAdmin {
+			if _, _, userErr := common.AccessLevelUser.CheckAccessFrom(a.config.Settings, headers); userErr == nil {
+				return nil, status.Error(codes.PermissionDenied, "admin access required")
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, accessErr.Message)
+	}
+
+	if level == common.AccessLevelUser || level == common.AccessLevelAdmin {
+		for _, permission := range a.config.Permissions[method] {
+			if !utils.IsInArray(permission, permissions) {
+				return nil, status.Errorf(codes.PermissionDenied, "missing permission %s", permission)
+			}
+		}
+	}
+
+	ctx = common.WithAccessPermissions(common.WithAccessId(ctx, accessId), permissions)
+	return common.WithProject(ctx, common.ParseProjectFromString(headers.Header("Project-ID"))), nil
+}
+
+// metadataHeaders reads the headers from the incoming metadata of ctx, keys are case-insensitive.
+func metadataHeaders(ctx context.Context) common.HeaderSource {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return common.HeaderFunc(func(name string) string {
+		if values := md.Get(name); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	})
+}
+
+// contextStream is a grpc.ServerStream whose context is replaced.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context implements grpc.ServerStream.
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
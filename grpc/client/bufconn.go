@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufconnSize is the buffer size of the in-memory connections.
+const bufconnSize = 1 << 20
+
+// Bufconn serves a gRPC server over an in-memory listener, so that tests can call real services without a network.
+//
+// Example usage:
+//
+//	server, _ := grpc.NewServer(grpc.ServerConfig{UnaryInterceptors: []grpc_lib.UnaryServerInterceptor{auth.UnaryServerInterceptor()}})
+//	pb.RegisterDeviceServiceServer(server.Srv, deviceService)
+//	buf := client.NewBufconn(server.Srv)
+//	defer buf.Close()
+//	conn, err := buf.Dial(client.Options{APIKey: "secret"})
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	devices := pb.NewDeviceServiceClient(conn)
+type Bufconn struct {
+	listener *bufconn.Listener
+	server   *grpc.Server
+}
+
+// NewBufconn starts serving server over an in-memory listener, register the services before calling it.
+func NewBufconn(server *grpc.Server) *Bufconn {
+	listener := bufconn.Listen(bufconnSize)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	return &Bufconn{
+		listener: listener,
+		server:   server,
+	}
+}
+
+// Dial creates a client connection to the in-memory server with the same interceptors as New.
+// TLS and the extra dial options of options are ignored.
+func (b *Bufconn) Dial(options Options) (*grpc.ClientConn, error) {
+	dialOptions, err := options.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	dialOptions = append(dialOptions,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return b.listener.DialContext(ctx)
+		}),
+	)
+	return grpc.NewClient("passthrough:///bufconn", dialOptions...)
+}
+
+// Close stops the server and closes the listener.
+func (b *Bufconn) Close() {
+	b.server.Stop()
+	_ = b.listener.Close()
+}
@@ -0,0 +1,266 @@
+// Package client builds gRPC client connections to the services wrapped through config.WrapperConfig,
+// with default deadlines, retries, round-robin load balancing, keepalive, TLS, metadata propagation and typed errors.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/errors/grpc_errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// RetryPolicy is the retry policy of the calls, applied by gRPC through the service config.
+// It contains the following fields:
+// - MaxAttempts: the maximum number of attempts of a call including the first one, at most 5 (gRPC caps it)
+// - InitialBackoff, MaxBackoff: the bounds of the randomized delay between attempts
+// - BackoffMultiplier: the growth factor of the delay between attempts
+// - RetryableStatusCodes: the status codes retried, others are returned at once
+type RetryPolicy struct {
+	MaxAttempts          int
+	InitialBackoff       time.Duration
+	MaxBackoff           time.Duration
+	BackoffMultiplier    float64
+	RetryableStatusCodes []codes.Code
+}
+
+// DefaultRetryPolicy retries unavailable services up to 3 times in total.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:          3,
+	InitialBackoff:       100 * time.Millisecond,
+	MaxBackoff:           time.Second,
+	BackoffMultiplier:    2,
+	RetryableStatusCodes: []codes.Code{codes.Unavailable},
+}
+
+// TLSConfig holds the certificates of a client using TLS.
+// It contains the following fields:
+// - CAFile: the PEM encoded CAs verifying the server, empty uses the system pool
+// - CertFile, KeyFile: the PEM encoded certificate and private key of the client, for servers requiring mTLS
+// - ServerName: overrides the name verified in the server certificate, defaults to the host of the target
+type TLSConfig struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// Options configures the connections built by New.
+// It contains the following fields:
+// - Timeout: the deadline of unary calls whose context has none, defaults to WrapperConfig.TimeoutSec, 0 sets none
+// - Retry: the retry policy of the calls, defaults to DefaultRetryPolicy
+// - DisableRetry: disables the retries
+// - LoadBalancingPolicy: the gRPC load balancing policy, defaults to round_robin over the addresses resolved by DNS
+// - Keepalive: the keepalive parameters, defaults to a ping after 5 minutes without activity (the minimum the servers accept by default)
+// - TLS: the certificates of the client, nil connects in plaintext
+// - APIKey: the service API key sent as api-key with every call that does not carry one
+// - PropagatedMetadata: the incoming metadata keys forwarded to the calls made while serving an RPC, defaults to DefaultPropagatedMetadata
+// - DisablePropagation: disables the propagation of the incoming metadata and of the correlation ID
+// - DisableErrorMapping: returns the status errors as is instead of rebuilding the typed errors (see grpc_errors.FromStatus)
+// - UnaryInterceptors, StreamInterceptors: chained after the built-in ones, the first one being the outermost
+// - DialOptions: extra gRPC dial options
+type Options struct {
+	Timeout             time.Duration
+	Retry               *RetryPolicy
+	DisableRetry        bool
+	LoadBalancingPolicy string
+	Keepalive           *keepalive.ClientParameters
+	TLS                 *TLSConfig
+	APIKey              string
+	PropagatedMetadata  []string
+	DisablePropagation  bool
+	DisableErrorMapping bool
+	UnaryInterceptors   []grpc.UnaryClientInterceptor
+	StreamInterceptors  []grpc.StreamClientInterceptor
+	DialOptions         []grpc.DialOption
+}
+
+// New creates a client connection to the service at cfg.GrpcUrl.
+//
+// Addresses without a scheme are resolved through DNS and the calls balanced across every resolved address.
+// The connection is established lazily on the first call, so New only fails on an invalid configuration.
+//
+// Example usage:
+//
+//	var cfg config.WrapperConfig // TIMEOUT_SEC=5, GRPC_URL=devices.internal:9090
+//	conn, err := client.New(cfg, client.Options{APIKey: settings.GetServiceSecret()})
+//	if err != nil {
+//	    return err
+//	}
+//	defer conn.Close()
+//	devices := pb.NewDeviceServiceClient(conn)
+//	device, err := devices.Get(request.Ctx(), &pb.GetRequest{Id: id})
+func New(cfg config.WrapperConfig, options Options) (*grpc.ClientConn, error) {
+	if cfg.GrpcUrl == "" {
+		return nil, fmt.Errorf("no grpc url to dial")
+	}
+	if options.Timeout == 0 && cfg.TimeoutSec > 0 {
+		options.Timeout = time.Duration(cfg.TimeoutSec) * time.Second
+	}
+
+	dialOptions, err := options.dialOptions()
+	if err != nil {
+		return nil, err
+	}
+	if options.TLS != nil {
+		creds, err := options.TLS.credentials()
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(creds))
+	} else {
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	return grpc.NewClient(target(cfg.GrpcUrl), append(dialOptions, options.DialOptions...)...)
+}
+
+// target prefixes addresses without a scheme with dns:///.
+func target(url string) string {
+	if strings.Contains(url, ":///") {
+		return url
+	}
+	return "dns:///" + url
+}
+
+// dialOptions returns the dial options of the service config, keepalive and interceptors.
+func (o Options) dialOptions() ([]grpc.DialOption, error) {
+	serviceConfig, err := o.serviceConfig()
+	if err != nil {
+		return nil, err
+	}
+	keepaliveParams := keepalive.ClientParameters{
+		Time:    5 * time.Minute,
+		Timeout: 20 * time.Second,
+	}
+	if o.Keepalive != nil {
+		keepaliveParams = *o.Keepalive
+	}
+
+	unary := make([]grpc.UnaryClientInterceptor, 0, len(o.UnaryInterceptors)+3)
+	stream := make([]grpc.StreamClientInterceptor, 0, len(o.StreamInterceptors)+2)
+	if !o.DisableErrorMapping {
+		unary = append(unary, grpc_errors.UnaryClientInterceptor())
+		stream = append(stream, grpc_errors.StreamClientInterceptor())
+	}
+	if o.Timeout > 0 {
+		unary = append(unary, timeoutInterceptor(o.Timeout))
+	}
+	propagator := o.propagator()
+	unary = append(append(unary, propagator.unaryInterceptor()), o.UnaryInterceptors...)
+	stream = append(append(stream, propagator.streamInterceptor()), o.StreamInterceptors...)
+
+	return []grpc.DialOption{
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithKeepaliveParams(keepaliveParams),
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}, nil
+}
+
+// serviceConfig returns the JSON service config holding the load balancing and retry policies.
+func (o Options) serviceConfig() (string, error) {
+	type retryPolicy struct {
+		MaxAttempts          int          `json:"maxAttempts"`
+		InitialBackoff       string       `json:"initialBackoff"`
+		MaxBackoff           string       `json:"maxBackoff"`
+		BackoffMultiplier    float64      `json:"backoffMultiplier"`
+		RetryableStatusCodes []codes.Code `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []struct{}   `json:"name"`
+		RetryPolicy *retryPolicy `json:"retryPolicy,omitempty"`
+	}
+	type serviceConfig struct {
+		LoadBalancingConfig []map[string]struct{} `json:"loadBalancingConfig"`
+		MethodConfig        []methodConfig        `json:"methodConfig,omitempty"`
+	}
+
+	policy := o.LoadBalancingPolicy
+	if policy == "" {
+		policy = "round_robin"
+	}
+	sc := serviceConfig{
+		LoadBalancingConfig: []map[string]struct{}{{policy: {}}},
+	}
+	if !o.DisableRetry {
+		retry := DefaultRetryPolicy
+		if o.Retry != nil {
+			retry = *o.Retry
+		}
+		if retry.MaxAttempts < 2 || len(retry.RetryableStatusCodes) == 0 {
+			return "", fmt.Errorf("a grpc retry policy needs at least 2 attempts and a retryable status code")
+		}
+		sc.MethodConfig = []methodConfig{{
+			// An empty name applies the policy to every method
+			Name: []struct{}{{}},
+			RetryPolicy: &retryPolicy{
+				MaxAttempts:          retry.MaxAttempts,
+				InitialBackoff:       seconds(retry.InitialBackoff),
+				MaxBackoff:           seconds(retry.MaxBackoff),
+				BackoffMultiplier:    retry.BackoffMultiplier,
+				RetryableStatusCodes: retry.RetryableStatusCodes,
+			},
+		}}
+	}
+
+	raw, err := json.Marshal(sc)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// seconds formats a duration as the service config expects it, e.g. 0.1s.
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+// timeoutInterceptor sets a deadline on the unary calls whose context has none.
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// credentials loads the TLS credentials of the client.
+func (t *TLSConfig) credentials() (credentials.TransportCredentials, error) {
+	tlsConfig := &tls.Config{
+		ServerName: t.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read grpc server CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load grpc client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/shiroyaavish/go-common/common"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultPropagatedMetadata are the incoming metadata keys forwarded by default: the credentials and the project of the caller.
+var DefaultPropagatedMetadata = []string{"authorization", "api-key", "x-api-key", "project-id"}
+
+// correlationIdKey is the metadata key of the correlation ID.
+var correlationIdKey = strings.ToLower(common.CorrelationIdHeader)
+
+// propagator adds the propagated metadata, the correlation ID and the API key to outgoing calls.
+type propagator struct {
+	keys     []string
+	apiKey   string
+	disabled bool
+}
+
+// propagator returns the propagator of the options.
+func (o Options) propagator() propagator {
+	keys := o.PropagatedMetadata
+	if keys == nil {
+		keys = DefaultPropagatedMetadata
+	}
+	return propagator{
+		keys:     keys,
+		apiKey:   o.APIKey,
+		disabled: o.DisablePropagation,
+	}
+}
+
+// outgoing returns ctx with the metadata to send, values already set on the outgoing metadata are kept.
+//
+// The correlation ID is taken from the context (see common.WithCorrelationId), then from the incoming metadata,
+// and generated when there is none, so that every call can be traced across services.
+func (p propagator) outgoing(ctx context.Context) context.Context {
+	out, _ := metadata.FromOutgoingContext(ctx)
+	pairs := make([]string, 0, 2*len(p.keys)+4)
+	add := func(key, value string) {
+		if value != "" && len(out.Get(key)) == 0 {
+			pairs = append(pairs, key, value)
+			out = metadata.Join(out, metadata.Pairs(key, value))
+		}
+	}
+
+	if !p.disabled {
+		in, _ := metadata.FromIncomingContext(ctx)
+		for _, key := range p.keys {
+			if values := in.Get(key); len(values) > 0 {
+				add(strings.ToLower(key), values[0])
+			}
+		}
+
+		correlationId, ok := common.CorrelationIdFromContext(ctx)
+		if !ok {
+			if values := in.Get(correlationIdKey); len(values) > 0 {
+				correlationId = values[0]
+			} else {
+				correlationId = uuid.NewString()
+			}
+		}
+		add(correlationIdKey, correlationId)
+	}
+	add("api-key", p.apiKey)
+
+	if len(pairs) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...)
+}
+
+// unaryInterceptor returns the interceptor adding the metadata to unary calls.
+func (p propagator) unaryInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(p.outgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// streamInterceptor returns the interceptor adding the metadata to streaming calls.
+func (p propagator) streamInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(p.outgoing(ctx), desc, cc, method, opts...)
+	}
+}
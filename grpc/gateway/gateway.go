@@ -0,0 +1,158 @@
+// Package gateway serves gRPC services as JSON over HTTP on the fiber Router, through grpc-gateway,
+// so that a service exposing both gRPC and REST keeps a single set of handlers.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	goErrors "errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/google/uuid"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/errors"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/errors/db_errors"
+	"github.com/shiroyaavish/go-common/errors/grpc_errors"
+	"github.com/shiroyaavish/go-common/http_server"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultForwardedHeaders are the HTTP headers forwarded as gRPC metadata by default: the credentials read by
+// grpc.Authenticator, the project and the correlation ID of the caller.
+var DefaultForwardedHeaders = []string{"Authorization", "Api-Key", "X-Api-Key", "Project-ID", common.CorrelationIdHeader}
+
+// Config configures a Gateway.
+// It contains the following fields:
+// - Prefix: the path the gateway is mounted under, stripped before matching the HTTP rules of the services, e.g. /rpc
+// - ForwardedHeaders: the HTTP headers forwarded as gRPC metadata (keys in lower case), defaults to DefaultForwardedHeaders, Authorization is always forwarded
+// - MuxOptions: extra grpc-gateway options, e.g. marshalers, applied after the ones of the gateway
+type Config struct {
+	Prefix           string
+	ForwardedHeaders []string
+	MuxOptions       []runtime.ServeMuxOption
+}
+
+// Gateway transcodes JSON requests into calls of gRPC services, following the google.api.http rules of their protos.
+//
+// Mounted on a http_server.Router, the requests go through the middleware of the Router (recovery, logging, CORS,
+// compression). A correlation ID is generated for requests without one. Errors are answered with the
+// http_server.ResponseData envelope of RequestHandlerBuilder, with the HTTP status matching the error returned by
+// the service (see grpc_errors.FromStatus).
+// Server streaming methods are answered once the stream ends, fasthttp does not flush partial responses.
+//
+// Example usage:
+//
+//	gw := gateway.New(gateway.Config{Prefix: "/rpc"})
+//	conn, err := client.New(wrapperConfig, client.Options{})
+//	if err != nil {
+//	    return err
+//	}
+//	if err := pb.RegisterDeviceServiceHandler(ctx, gw.Mux(), conn); err != nil {
+//	    return err
+//	}
+//	gw.Mount(router) // GET /rpc/v1/devices/{id} calls DeviceService.Get
+type Gateway struct {
+	prefix string
+	mux    *runtime.ServeMux
+}
+
+// startCtxKey is the context key holding the time the request was received.
+type startCtxKey struct{}
+
+// New creates a Gateway from the config, register the services on Mux before mounting it.
+func New(cfg Config) *Gateway {
+	forwarded := cfg.ForwardedHeaders
+	if forwarded == nil {
+		forwarded = DefaultForwardedHeaders
+	}
+	headers := make(map[string]struct{}, len(forwarded))
+	for _, header := range forwarded {
+		headers[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	// grpc-gateway always forwards Authorization itself
+	delete(headers, "Authorization")
+
+	options := []runtime.ServeMuxOption{
+		runtime.WithIncomingHeaderMatcher(func(key string) (string, bool) {
+			if _, ok := headers[http.CanonicalHeaderKey(key)]; ok {
+				return strings.ToLower(key), true
+			}
+			return runtime.DefaultHeaderMatcher(key)
+		}),
+		runtime.WithMetadata(func(_ context.Context, r *http.Request) metadata.MD {
+			if r.Header.Get(common.CorrelationIdHeader) != "" {
+				return nil
+			}
+			return metadata.Pairs(strings.ToLower(common.CorrelationIdHeader), uuid.NewString())
+		}),
+		runtime.WithErrorHandler(errorHandler),
+	}
+	return &Gateway{
+		prefix: strings.TrimSuffix(cfg.Prefix, "/"),
+		mux:    runtime.NewServeMux(append(options, cfg.MuxOptions...)...),
+	}
+}
+
+// Mux returns the grpc-gateway mux the services are registered on,
+// with the generated Register<Service>Handler or Register<Service>HandlerServer functions.
+func (g *Gateway) Mux() *runtime.ServeMux {
+	return g.mux
+}
+
+// Handler returns the gateway as a net/http handler, serving the paths under the prefix.
+func (g *Gateway) Handler() http.Handler {
+	return http.StripPrefix(g.prefix, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), startCtxKey{}, time.Now())))
+	}))
+}
+
+// Mount serves the gateway on router under the prefix, after the middleware already registered on the router.
+func (g *Gateway) Mount(router *http_server.Router) {
+	handler := adaptor.HTTPHandler(g.Handler())
+	prefix := g.prefix
+	if prefix == "" {
+		prefix = "/"
+	}
+	router.Use(prefix, func(c *fiber.Ctx) error {
+		return handler(c)
+	})
+}
+
+// errorHandler answers the errors of the services with the ResponseData envelope.
+func errorHandler(ctx context.Context, _ *runtime.ServeMux, _ runtime.Marshaler, w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := apiErrorOf(err)
+	response := http_server.ResponseData{
+		Message:  apiErr.Message,
+		Hostname: r.Host,
+	}
+	if start, ok := ctx.Value(startCtxKey{}).(time.Time); ok {
+		response.Duration = time.Since(start).Milliseconds()
+	}
+
+	w.Header().Set("Content-Type", fiber.MIMEApplicationJSON)
+	w.WriteHeader(apiErr.StatusCode)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// apiErrorOf returns the api_errors.Error answering an error of the gateway or of a service.
+func apiErrorOf(err error) api_errors.Error {
+	err = grpc_errors.FromStatus(err)
+	var apiErr api_errors.Error
+	if goErrors.As(err, &apiErr) {
+		return apiErr
+	}
+	var commonErr errors.Error
+	if goErrors.As(err, &commonErr) {
+		return api_errors.Error{StatusCode: commonErr.StatusCode, Message: commonErr.Message}
+	}
+	if dbErr, ok := db_errors.ToAPIError(err); ok {
+		return *dbErr
+	}
+	return *api_errors.ErrSomethingWentWrong
+}
@@ -1,66 +1,205 @@
 package grpc
 
 import (
-	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
-	"github.com/shiroyaavish/go-common/logger"
 	"net"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/shiroyaavish/go-common/config"
+	"github.com/shiroyaavish/go-common/logger"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/reflection"
 )
 
-// unaryServerInterceptor is a gRPC unary server interceptor that limits the number of inflight messages per user.
-// Args:
-//
-//	r: Redis client for performing operations on Redis server.
-//	maxMessages: Maximum number of inflight messages allowed per user.
-//
-// Returns:
-//
-//	A gRPC UnaryServerInterceptor function.
+// ReflectionMode decides whether the server reflection service is registered.
+type ReflectionMode int
+
+const (
+	// ReflectionByEnvironment enables reflection everywhere but in production.
+	ReflectionByEnvironment ReflectionMode = iota
+	ReflectionEnabled
+	ReflectionDisabled
+)
+
+// TLSConfig holds the certificates of a server using TLS.
+// It contains the following fields:
+// - CertFile, KeyFile: the PEM encoded certificate and private key of the server
+// - ClientCAFile: the PEM encoded CAs of the clients, enables mTLS: clients must present a certificate signed by them
+type TLSConfig struct {
+	CertFile     string
+	KeyFile      string
+	ClientCAFile string
+}
+
+// ServerConfig configures a GrpcServer.
+// It contains the following fields:
+// - Port: the port the server listens on, 0 picks a free port (see Addr)
+// - TLS: the certificates of the server, nil serves plaintext
+// - Keepalive: the keepalive parameters of the server, nil keeps the gRPC defaults
+// - KeepalivePolicy: the keepalive enforcement policy for clients, nil keeps the gRPC defaults
+// - MaxRecvMsgSize, MaxSendMsgSize: the largest messages in bytes, 0 keeps the gRPC defaults (4MB received, unlimited sent)
+// - UnaryInterceptors, StreamInterceptors: chained in order, the first one being the outermost, inside the built-in access logging and recovery
+// - DisableRecovery: disables the built-in recovery interceptors, which turn handler panics into codes.Internal errors
+// - AccessLog: logs every call with the built-in logging interceptors, the outermost ones
+// - ValidateRequests: validates the requests after UnaryInterceptors and StreamInterceptors, see ValidationUnaryServerInterceptor
+// - Validators: the extra validations of ValidateRequests, e.g. protovalidate
+// - Reflection: whether the reflection service is registered, by default everywhere but in production
+// - Settings: the configuration deciding the environment, defaults to config.Default()
+// - ServerOptions: extra gRPC server options
+type ServerConfig struct {
+	Port               int
+	TLS                *TLSConfig
+	Keepalive          *keepalive.ServerParameters
+	KeepalivePolicy    *keepalive.EnforcementPolicy
+	MaxRecvMsgSize     int
+	MaxSendMsgSize     int
+	UnaryInterceptors  []grpc.UnaryServerInterceptor
+	StreamInterceptors []grpc.StreamServerInterceptor
+	DisableRecovery    bool
+	AccessLog          bool
+	ValidateRequests   bool
+	Validators         []ValidatorFunc
+	Reflection         ReflectionMode
+	Settings           *config.Settings
+	ServerOptions      []grpc.ServerOption
+}
+
+// GrpcServer is a type that represents a gRPC server.
+type GrpcServer struct {
+	listener net.Listener
+	Srv      *grpc.Server
+	port     int
+
+	mu       sync.Mutex
+	serveErr chan error
+}
+
+// NewServer creates a GrpcServer from the config, register the services on Srv before starting it.
+// The server does not listen until Serve or StartAsync is called.
 //
-// Usage Example:
+// Example usage:
 //
-//	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(unaryServerInterceptor(redisClient, limit)))
-func unaryServerInterceptor(r *redis.Client, maxMessages int64) grpc.UnaryServerInterceptor {
-	return func(
-		ctx context.Context,
-		req any,
-		info *grpc.UnaryServerInfo,
-		handler grpc.UnaryHandler,
-	) (interface{}, error) {
-		userID := ctx.Value("userID").(string)
-
-		userInflightCount, err := r.Incr(ctx, userID).Result()
+//	server, err := grpc.NewServer(grpc.ServerConfig{
+//	    Port:              9090,
+//	    TLS:               &grpc.TLSConfig{CertFile: "server.pem", KeyFile: "server.key", ClientCAFile: "clients.pem"},
+//	    MaxRecvMsgSize:    16 << 20,
+//	    AccessLog:         true,
+//	    ValidateRequests:  true,
+//	    UnaryInterceptors: []grpc_lib.UnaryServerInterceptor{grpc_errors.UnaryServerInterceptor(), auth.UnaryServerInterceptor()},
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	pb.RegisterDeviceServiceServer(server.Srv, deviceService)
+//	if err := server.StartAsync(); err != nil {
+//	    return err
+//	}
+//	defer server.GracefulStop(10 * time.Second)
+func NewServer(cfg ServerConfig) (*GrpcServer, error) {
+	options := make([]grpc.ServerOption, 0, len(cfg.ServerOptions)+6)
+	if cfg.TLS != nil {
+		creds, err := cfg.TLS.credentials()
 		if err != nil {
 			return nil, err
 		}
+		options = append(options, grpc.Creds(creds))
+	}
+	if cfg.Keepalive != nil {
+		options = append(options, grpc.KeepaliveParams(*cfg.Keepalive))
+	}
+	if cfg.KeepalivePolicy != nil {
+		options = append(options, grpc.KeepaliveEnforcementPolicy(*cfg.KeepalivePolicy))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		options = append(options, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	unary, stream := cfg.interceptors()
+	if len(unary) > 0 {
+		options = append(options, grpc.ChainUnaryInterceptor(unary...))
+	}
+	if len(stream) > 0 {
+		options = append(options, grpc.ChainStreamInterceptor(stream...))
+	}
+	options = append(options, cfg.ServerOptions...)
 
-		if userInflightCount > maxMessages {
-			return nil, fmt.Errorf("Max inflight messages reached")
-		}
-
-		resp, err := handler(ctx, req)
-		if err != nil {
-			return nil, err
-		}
+	srv := grpc.NewServer(options...)
+	if cfg.Reflection.enabled(config.OrDefault(cfg.Settings)) {
+		reflection.Register(srv)
+	}
+	return &GrpcServer{
+		Srv:  srv,
+		port: cfg.Port,
+	}, nil
+}
 
-		_, err = r.Decr(ctx, userID).Result()
-		if err != nil {
-			return nil, err
-		}
+// interceptors returns the configured interceptors surrounded by the built-in ones.
+func (cfg ServerConfig) interceptors() ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	unary := make([]grpc.UnaryServerInterceptor, 0, len(cfg.UnaryInterceptors)+3)
+	stream := make([]grpc.StreamServerInterceptor, 0, len(cfg.StreamInterceptors)+3)
+	if cfg.AccessLog {
+		unary = append(unary, LoggingUnaryServerInterceptor())
+		stream = append(stream, LoggingStreamServerInterceptor())
+	}
+	// Recovery comes inside the logging so that the panics are logged as codes.Internal calls
+	if !cfg.DisableRecovery {
+		unary = append(unary, RecoveryUnaryServerInterceptor())
+		stream = append(stream, RecoveryStreamServerInterceptor())
+	}
+	unary = append(unary, cfg.UnaryInterceptors...)
+	stream = append(stream, cfg.StreamInterceptors...)
+	if cfg.ValidateRequests {
+		unary = append(unary, ValidationUnaryServerInterceptor(cfg.Validators...))
+		stream = append(stream, ValidationStreamServerInterceptor(cfg.Validators...))
+	}
+	return unary, stream
+}
 
-		return resp, err
+// enabled reports whether reflection is enabled in the environment of settings.
+func (m ReflectionMode) enabled(settings *config.Settings) bool {
+	switch m {
+	case ReflectionEnabled:
+		return true
+	case ReflectionDisabled:
+		return false
+	default:
+		return settings.GetCurrentEnvironment() != config.Production
 	}
 }
 
-// GrpcServer is a type that represents a gRPC server.
-type GrpcServer struct {
-	listener    net.Listener
-	Srv         *grpc.Server
-	port        int
-	redisClient *redis.Client
+// credentials loads the TLS credentials of the server.
+func (t *TLSConfig) credentials() (credentials.TransportCredentials, error) {
+	certificate, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load grpc server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.ClientCAFile != "" {
+		pem, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read grpc client CAs: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
 }
 
 // New is a function that creates a new instance of the GrpcServer struct.
@@ -68,7 +207,7 @@ type GrpcServer struct {
 //
 //	port: The port number on which the server will listen for incoming connections.
 //	limit: The maximum number of inflight messages allowed per user.
-//	redisClient: The Redis client used for performing operations on the Redis server.
+//	redisClient: The Redis client used for performing operations on the Redis server, shared with the caller.
 //
 // Returns:
 //
@@ -77,46 +216,125 @@ type GrpcServer struct {
 // Usage Example:
 //
 //	server := New(8080, 10, redisClient)
+//
+// Deprecated: use NewServer with a Limiter, which supports TLS, keepalive, stream limits and custom interceptors.
 func New(port int, limit int64, redisClient *redis.Client) *GrpcServer {
-	lis, err := net.Listen("tcp", fmt.Sprint(":", port))
+	limiter := newLimiter(redisV8Store{client: redisClient}, LimiterConfig{Limit: limit})
+	server, _ := NewServer(ServerConfig{
+		Port:               port,
+		UnaryInterceptors:  []grpc.UnaryServerInterceptor{limiter.UnaryServerInterceptor()},
+		StreamInterceptors: []grpc.StreamServerInterceptor{limiter.StreamServerInterceptor()},
+		Reflection:         ReflectionDisabled,
+	})
+	return server
+}
+
+// listen opens the listener of the server once.
+func (g *GrpcServer) listen() (net.Listener, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.listener != nil {
+		return nil, errors.New("grpc server already started")
+	}
+	lis, err := net.Listen("tcp", fmt.Sprint(":", g.port))
 	if err != nil {
-		logger.Fatal(err)
+		return nil, fmt.Errorf("cannot listen on port %d: %w", g.port, err)
 	}
-	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(unaryServerInterceptor(redisClient, limit)))
-	return &GrpcServer{
-		listener:    lis,
-		Srv:         grpcServer,
-		port:        port,
-		redisClient: redisClient,
+	g.listener = lis
+	return lis, nil
+}
+
+// Addr returns the address the server listens on, nil before it is started.
+func (g *GrpcServer) Addr() net.Addr {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.listener == nil {
+		return nil
 	}
+	return g.listener.Addr()
+}
+
+// Serve listens on the port of the server and serves until it is stopped. It returns nil after a stop.
+func (g *GrpcServer) Serve() error {
+	lis, err := g.listen()
+	if err != nil {
+		return err
+	}
+	logger.Info("Started Server on %s", lis.Addr())
+	if err := g.Srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+	return nil
 }
 
 // StartAsync starts the gRPC server asynchronously.
 //
-// It launches a goroutine that calls Serve on the listener of the gRPC server.
-//
-// The function logs a fatal error if Serve returns an error, and logs an info message when the server successfully starts.
+// It listens on the port of the server, returning the error when it cannot, and serves in a goroutine.
+// An error of the server while serving is logged and returned by Wait.
 //
 // This method should be called after setting all the necessary configurations and registering the server with gRPC.
 //
 // Example usage:
 //
-//	gs := &GrpcServer{
-//	    listener:    listener,
-//	    Srv:         srv,
-//	    port:        port,
-//	    redisClient: redisClient,
+//	if err := server.StartAsync(); err != nil {
+//	    return err
 //	}
-//	gs.StartAsync()
-//
-// Returns nothing.
-func (g *GrpcServer) StartAsync() {
+func (g *GrpcServer) StartAsync() error {
+	lis, err := g.listen()
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	g.serveErr = make(chan error, 1)
+	serveErr := g.serveErr
+	g.mu.Unlock()
+
 	go func() {
-		err := g.Srv.Serve(g.listener)
-		if err != nil {
-			logger.Fatal(err, "failed to start grpc server")
-			return
+		err := g.Srv.Serve(lis)
+		if err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			logger.Error(err, "grpc server stopped serving")
+			serveErr <- err
 		}
+		close(serveErr)
+	}()
+	logger.Info("Started Server on %s", lis.Addr())
+	return nil
+}
+
+// Wait blocks until the server started by StartAsync stops, returning the error it stopped with, if any.
+func (g *GrpcServer) Wait() error {
+	g.mu.Lock()
+	serveErr := g.serveErr
+	g.mu.Unlock()
+	if serveErr == nil {
+		return nil
+	}
+	return <-serveErr
+}
+
+// GracefulStop stops accepting connections and waits for the pending RPCs to finish, for at most timeout.
+// After the timeout the remaining RPCs are cancelled through Stop. It returns true when the stop was graceful.
+func (g *GrpcServer) GracefulStop(timeout time.Duration) bool {
+	stopped := make(chan struct{})
+	go func() {
+		g.Srv.GracefulStop()
+		close(stopped)
 	}()
-	logger.Info("Started Server on %d", g.port)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-stopped:
+		return true
+	case <-timer.C:
+		logger.Warn("grpc server did not stop within %s, cancelling the pending RPCs", timeout)
+		g.Srv.Stop()
+		<-stopped
+		return false
+	}
+}
+
+// Stop closes every connection and cancels the pending RPCs immediately.
+func (g *GrpcServer) Stop() {
+	g.Srv.Stop()
 }
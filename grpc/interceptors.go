@@ -0,0 +1,185 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// ValidatorFunc validates a request message, returning the reason it is invalid.
+//
+// Example usage with protovalidate:
+//
+//	validator, err := protovalidate.New()
+//	if err != nil {
+//	    return err
+//	}
+//	validate := grpc.ValidatorFunc(func(msg any) error {
+//	    if m, ok := msg.(proto.Message); ok {
+//	        return validator.Validate(m)
+//	    }
+//	    return nil
+//	})
+type ValidatorFunc func(msg any) error
+
+// validatable is implemented by the messages generated with a Validate method (e.g. protoc-gen-validate).
+type validatable interface {
+	Validate() error
+}
+
+// RecoveryUnaryServerInterceptor returns the interceptor turning panics of unary handlers into codes.Internal errors.
+// The panic is logged with its stack. NewServer installs it unless ServerConfig.DisableRecovery is set.
+func RecoveryUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamServerInterceptor returns the interceptor turning panics of streaming handlers into codes.Internal errors.
+func RecoveryStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+// recovered logs a recovered panic and returns the error answering the call.
+func recovered(method string, r any) error {
+	logger.Logger.Error().Str("stack", string(debug.Stack())).Str("method", method).Msg(fmt.Sprintf("panic in grpc handler: %v", r))
+	return status.Error(codes.Internal, api_errors.ErrSomethingWentWrong.Message)
+}
+
+// LoggingUnaryServerInterceptor returns the interceptor logging every unary call with its method, code, latency,
+// peer and correlation ID. Server errors are logged at the error level, client errors at the warning level.
+func LoggingUnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// LoggingStreamServerInterceptor returns the interceptor logging every streaming call once it ends.
+func LoggingStreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), info.FullMethod, start, err)
+		return err
+	}
+}
+
+// logCall logs the outcome of a call.
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	var event *zerolog.Event
+	switch code {
+	case codes.OK:
+		event = logger.Logger.Info()
+	case codes.Unknown, codes.Internal, codes.DataLoss, codes.Unimplemented, codes.Unavailable, codes.DeadlineExceeded:
+		event = logger.Logger.Error().Err(err)
+	default:
+		event = logger.Logger.Warn().Err(err)
+	}
+
+	event = event.Str("method", method).
+		Str("code", code.String()).
+		Dur("latency", time.Since(start))
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		event = event.Str("peer", p.Addr.String())
+	}
+	if correlationId, ok := correlationIdOf(ctx); ok {
+		event = event.Str("correlation_id", correlationId)
+	}
+	event.Msg("grpc call")
+}
+
+// correlationIdOf returns the correlation ID of the call, from the context or the incoming metadata.
+func correlationIdOf(ctx context.Context) (string, bool) {
+	if correlationId, ok := common.CorrelationIdFromContext(ctx); ok {
+		return correlationId, true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(strings.ToLower(common.CorrelationIdHeader)); len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// ValidationUnaryServerInterceptor returns the interceptor rejecting invalid requests with codes.InvalidArgument.
+// Requests are validated by their Validate method when they have one, then by each of validators.
+func ValidationUnaryServerInterceptor(validators ...ValidatorFunc) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := validate(req, validators); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// ValidationStreamServerInterceptor returns the interceptor rejecting invalid messages received on streams
+// with codes.InvalidArgument, the error is returned by RecvMsg to the handler.
+func ValidationStreamServerInterceptor(validators ...ValidatorFunc) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &validatingStream{ServerStream: ss, validators: validators})
+	}
+}
+
+// validate validates msg, returning a status error.
+func validate(msg any, validators []ValidatorFunc) error {
+	if v, ok := msg.(validatable); ok {
+		if err := v.Validate(); err != nil {
+			return invalidArgument(err)
+		}
+	}
+	for _, validator := range validators {
+		if err := validator(msg); err != nil {
+			return invalidArgument(err)
+		}
+	}
+	return nil
+}
+
+// invalidArgument returns err as a codes.InvalidArgument status error, errors carrying a status keep it.
+func invalidArgument(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// validatingStream is a grpc.ServerStream validating the messages it receives.
+type validatingStream struct {
+	grpc.ServerStream
+	validators []ValidatorFunc
+}
+
+// RecvMsg implements grpc.ServerStream.
+func (s *validatingStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	return validate(m, s.validators)
+}
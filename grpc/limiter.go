@@ -0,0 +1,274 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	redisv8 "github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/logger"
+	"github.com/shiroyaavish/go-common/redis"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultLimiterKeyPrefix prefixes the Redis keys holding the inflight leases.
+const DefaultLimiterKeyPrefix = "go_common:grpc_inflight:"
+
+// acquireSource drops the expired leases of a key and adds a lease when there are fewer than the limit.
+// The expiries use the Redis clock, so that instances with drifting clocks agree.
+const acquireSource = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", now)
+if redis.call("ZCARD", KEYS[1]) >= tonumber(ARGV[1]) then
+    return 0
+end
+redis.call("ZADD", KEYS[1], now + tonumber(ARGV[3]), ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1
+`
+
+// renewSource extends an existing lease.
+const renewSource = `
+local t = redis.call("TIME")
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local renewed = redis.call("ZADD", KEYS[1], "XX", "CH", now + tonumber(ARGV[2]), ARGV[1])
+redis.call("PEXPIRE", KEYS[1], ARGV[2])
+return renewed
+`
+
+var (
+	acquireScript   = goredis.NewScript(acquireSource)
+	renewScript     = goredis.NewScript(renewSource)
+	acquireScriptV8 = redisv8.NewScript(acquireSource)
+	renewScriptV8   = redisv8.NewScript(renewSource)
+)
+
+// leaseStore holds the leases of a Limiter, implemented on the go-redis v9 client and, for the deprecated New,
+// on the go-redis v8 client of the caller.
+type leaseStore interface {
+	acquire(ctx context.Context, key string, limit int64, member string, ttl int64) (bool, error)
+	renew(ctx context.Context, key, member string, ttl int64) error
+	release(ctx context.Context, key, member string) error
+}
+
+// redisStore is a leaseStore on a go-redis v9 client.
+type redisStore struct {
+	client *goredis.Client
+}
+
+func (s redisStore) acquire(ctx context.Context, key string, limit int64, member string, ttl int64) (bool, error) {
+	acquired, err := acquireScript.Run(ctx, s.client, []string{key}, limit, member, ttl).Int()
+	return acquired == 1, err
+}
+
+func (s redisStore) renew(ctx context.Context, key, member string, ttl int64) error {
+	return renewScript.Run(ctx, s.client, []string{key}, member, ttl).Err()
+}
+
+func (s redisStore) release(ctx context.Context, key, member string) error {
+	return s.client.ZRem(ctx, key, member).Err()
+}
+
+// redisV8Store is a leaseStore on a go-redis v8 client.
+type redisV8Store struct {
+	client *redisv8.Client
+}
+
+func (s redisV8Store) acquire(ctx context.Context, key string, limit int64, member string, ttl int64) (bool, error) {
+	acquired, err := acquireScriptV8.Run(ctx, s.client, []string{key}, limit, member, ttl).Int()
+	return acquired == 1, err
+}
+
+func (s redisV8Store) renew(ctx context.Context, key, member string, ttl int64) error {
+	return renewScriptV8.Run(ctx, s.client, []string{key}, member, ttl).Err()
+}
+
+func (s redisV8Store) release(ctx context.Context, key, member string) error {
+	return s.client.ZRem(ctx, key, member).Err()
+}
+
+// IdentityFunc returns the identity of the caller of an RPC, the key its inflight requests are counted under.
+type IdentityFunc func(ctx context.Context) (string, bool)
+
+// LimiterConfig configures a Limiter.
+// It contains the following fields:
+// - Limit: the maximum number of inflight RPCs per caller, 0 or less disables the default limit
+// - MethodLimits: limits per full method name (`/package.Service/Method`), counted separately from Limit, 0 or less exempts the method
+// - LeaseTTL: how long a slot survives without renewal, bounding how long slots of a crashed instance stay taken, defaults to 30 seconds
+// - KeyPrefix: the prefix of the Redis keys, defaults to DefaultLimiterKeyPrefix
+// - Identity: derives the caller identity, defaults to DefaultIdentity
+// - InstanceID: identifies this instance in the leases, defaults to the hostname
+type LimiterConfig struct {
+	Limit        int64
+	MethodLimits map[string]int64
+	LeaseTTL     time.Duration
+	KeyPrefix    string
+	Identity     IdentityFunc
+	InstanceID   string
+}
+
+// Limiter limits the number of concurrent RPCs of each caller across every instance of a service.
+//
+// Each inflight RPC holds a lease in a Redis sorted set per caller (and per method with a MethodLimits entry).
+// Leases are released when the RPC returns, whatever its outcome, and renewed while it runs. A crashed instance
+// cannot leak slots: its leases expire after LeaseTTL. RPCs over the limit fail with codes.ResourceExhausted.
+// When Redis is unreachable RPCs are let through, so that the limiter never takes the service down.
+//
+// Example usage:
+//
+//	limiter := grpc.NewLimiter(redisClient, grpc.LimiterConfig{
+//	    Limit:        20,
+//	    MethodLimits: map[string]int64{"/devices.DeviceService/Export": 1},
+//	})
+//	server, err := grpc.NewServer(grpc.ServerConfig{
+//	    UnaryInterceptors:  []grpc_lib.UnaryServerInterceptor{authInterceptor, limiter.UnaryServerInterceptor()},
+//	    StreamInterceptors: []grpc_lib.StreamServerInterceptor{authStreamInterceptor, limiter.StreamServerInterceptor()},
+//	})
+type Limiter struct {
+	store  leaseStore
+	config LimiterConfig
+}
+
+// NewLimiter creates a Limiter storing its leases in client.
+func NewLimiter(client *redis.RedisClient, cfg LimiterConfig) *Limiter {
+	return newLimiter(redisStore{client: client.Raw()}, cfg)
+}
+
+// newLimiter creates a Limiter storing its leases in store.
+func newLimiter(store leaseStore, cfg LimiterConfig) *Limiter {
+	if cfg.LeaseTTL <= 0 {
+		cfg.LeaseTTL = 30 * time.Second
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultLimiterKeyPrefix
+	}
+	if cfg.Identity == nil {
+		cfg.Identity = DefaultIdentity
+	}
+	if cfg.InstanceID == "" {
+		cfg.InstanceID, _ = os.Hostname()
+	}
+	return &Limiter{
+		store:  store,
+		config: cfg,
+	}
+}
+
+// DefaultIdentity identifies the caller by, in order: the access ID set in the context by the authentication
+// interceptors, the subject of a verified TLS client certificate, and the peer IP address.
+func DefaultIdentity(ctx context.Context) (string, bool) {
+	if accessId, ok := common.AccessIdFromContext(ctx); ok {
+		return "access:" + accessId.String(), true
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok && len(tlsInfo.State.VerifiedChains) > 0 {
+		return "cert:" + tlsInfo.State.VerifiedChains[0][0].Subject.String(), true
+	}
+	if p.Addr != nil {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		return "ip:" + host, true
+	}
+	return "", false
+}
+
+// UnaryServerInterceptor returns the interceptor limiting unary RPCs.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		release, err := l.acquire(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns the interceptor limiting streaming RPCs, a stream holds its slot until it ends.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		release, err := l.acquire(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return handler(srv, ss)
+	}
+}
+
+// limitOf returns the Redis key and the limit applying to the method, false when it is not limited.
+func (l *Limiter) limitOf(identity, method string) (string, int64, bool) {
+	if limit, ok := l.config.MethodLimits[method]; ok {
+		return l.config.KeyPrefix + identity + ":" + method, limit, limit > 0
+	}
+	return l.config.KeyPrefix + identity, l.config.Limit, l.config.Limit > 0
+}
+
+// acquire takes a slot for the caller of ctx, returning the function releasing it.
+func (l *Limiter) acquire(ctx context.Context, method string) (func(), error) {
+	noop := func() {}
+	identity, ok := l.config.Identity(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "cannot identify the caller")
+	}
+	key, limit, limited := l.limitOf(identity, method)
+	if !limited {
+		return noop, nil
+	}
+
+	ttl := l.config.LeaseTTL.Milliseconds()
+	member := fmt.Sprintf("%s:%s", l.config.InstanceID, uuid.NewString())
+	acquired, err := l.store.acquire(ctx, key, limit, member, ttl)
+	if err != nil {
+		logger.Error(err, "cannot acquire grpc inflight slot, letting the call through")
+		return noop, nil
+	}
+	if !acquired {
+		return nil, status.Errorf(codes.ResourceExhausted, "too many inflight requests, the limit is %d", limit)
+	}
+
+	done := make(chan struct{})
+	go l.renew(key, member, done)
+	return func() {
+		close(done)
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := l.store.release(releaseCtx, key, member); err != nil {
+			logger.Error(err, "cannot release grpc inflight slot, it expires in "+l.config.LeaseTTL.String())
+		}
+	}, nil
+}
+
+// renew extends a lease every third of its TTL until done is closed.
+func (l *Limiter) renew(key, member string, done chan struct{}) {
+	ticker := time.NewTicker(l.config.LeaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), l.config.LeaseTTL/3)
+			err := l.store.renew(ctx, key, member, l.config.LeaseTTL.Milliseconds())
+			cancel()
+			if err != nil {
+				logger.Error(err, "cannot renew grpc inflight slot")
+			}
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package http_server
 
 import (
+	"context"
 	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/shiroyaavish/go-common/common"
+	"github.com/shiroyaavish/go-common/config"
 	"github.com/shiroyaavish/go-common/errors/api_errors"
+	"github.com/shiroyaavish/go-common/errors/db_errors"
 	"github.com/shiroyaavish/go-common/utils"
 	"log"
 	"os"
@@ -33,25 +36,64 @@ type RequestData[P any, Q any, B any] struct {
 	ProjectID         common.Project
 	AccessId          *uuid.UUID
 	AccessPermissions []string
+
+	flags FlagEvaluator
+}
+
+// FlagEvaluator evaluates feature flags for the caller of a request, it is implemented by flags.Registry.
+type FlagEvaluator interface {
+	IsEnabledFor(name string, projectID common.Project, accessId *uuid.UUID) bool
+}
+
+// FlagsFromCtx returns the FlagEvaluator injected by the Router serving the request, nil if there is none.
+func FlagsFromCtx(c *fiber.Ctx) FlagEvaluator {
+	if flags, ok := c.Locals(flagsLocalKey).(FlagEvaluator); ok {
+		return flags
+	}
+	return nil
+}
+
+// IsFlagEnabled reports whether the named feature flag is enabled for the project and access ID of the request.
+// It returns false when the Router has no FlagEvaluator configured.
+func (r RequestData[P, Q, B]) IsFlagEnabled(name string) bool {
+	if r.flags == nil {
+		return false
+	}
+	return r.flags.IsEnabledFor(name, r.ProjectID, r.AccessId)
+}
+
+// Ctx returns the context of the request, carrying the project, the access ID and the correlation ID of the caller
+// (see common.ProjectFromContext, common.AccessIdFromContext and common.CorrelationIdFromContext). Pass it to database
+// calls so that the db model plugin can fill the audit columns and scope queries to the project, and to gRPC clients
+// so that the correlation ID is propagated.
+func (r RequestData[P, Q, B]) Ctx() context.Context {
+	return r.Context.UserContext()
 }
 
 // Pagination represents the data structure for pagination in API responses.
 // It contains the limit and offset values for pagination.
 // Limit specifies the maximum number of items per page, while offset specifies the number of items to skip.
+// The Limit set by RequestHandlerBuilder includes one look-ahead item used to compute ResponseData.HasNext.
+//
+// For keyset pagination Cursor holds the `cursor` query parameter and the handler sets NextCursor
+// (e.g. through db.Repository.List), which is returned as ResponseData.NextCursor.
 // The JSON tags are used for serialization and deserialization.
 type Pagination struct {
-	Limit  int `json:"limit"`
-	Offset int `json:"offset"`
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset"`
+	Cursor     string `json:"cursor,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
 }
 
 // ResponseData represents the data structure used for handling HTTP responses.
 // It contains the response data, status of whether there is more data available, message, duration, and hostname.
 type ResponseData struct {
-	Data     any    `json:"data,omitempty"`
-	HasNext  bool   `json:"has_next,omitempty"`
-	Message  string `json:"message,omitempty"`
-	Duration int64  `json:"duration,omitempty"`
-	Hostname string `json:"hostname,omitempty"`
+	Data       any    `json:"data,omitempty"`
+	HasNext    bool   `json:"has_next,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Message    string `json:"message,omitempty"`
+	Duration   int64  `json:"duration,omitempty"`
+	Hostname   string `json:"hostname,omitempty"`
 }
 
 // ResponseDataWithCustomStatus is a data structure representing a response with a custom status code.
@@ -108,6 +150,8 @@ type RequestHandlerBuilder[P any, Q any, B any] struct {
 
 	IsPaginated bool
 	hostname    string
+
+	settings *config.Settings
 }
 
 // NewHandler creates a new route
@@ -177,6 +221,13 @@ func (r *RequestHandlerBuilder[P, Q, B]) SetProjectIdRequired() *RequestHandlerB
 	return r
 }
 
+// SetSettings sets the config.Settings used for the access checks of the handler.
+// When not set the settings injected by the Router (see SettingsFromCtx) are used.
+func (r *RequestHandlerBuilder[P, Q, B]) SetSettings(settings *config.Settings) *RequestHandlerBuilder[P, Q, B] {
+	r.settings = settings
+	return r
+}
+
 // Build is a method of RequestHandlerBuilder[P, Q, B] that returns a fiber.Handler.
 //
 // It builds a handler function for processing HTTP requests.
@@ -192,7 +243,7 @@ func (r *RequestHandlerBuilder[P, Q, B]) SetProjectIdRequired() *RequestHandlerB
 // - Validates any validation errors encountered during parameter parsing and returns an error response if necessary.
 // - Handles pagination if enabled, setting the pagination options based on the request query parameters.
 // - Executes the user-defined handler function with the request data as input.
-// - Handles any returned errors, returning an appropriate error response based on the error type.
+// - Handles any returned errors, returning an appropriate error response based on the error type (database errors through db_errors.ToAPIError).
 // - Handles custom status codes for the response if the returned data implements the ResponseDataWithCustomStatus interface.
 // - Processes paginated responses, slicing the result data if necessary.
 // - Calculates the duration of the request in milliseconds.
@@ -207,6 +258,7 @@ func (r *RequestHandlerBuilder[P, Q, B]) Build() fiber.Handler {
 		data := RequestData[P, Q, B]{
 			Context:   c,
 			ProjectID: common.ParseProjectFromString(c.Get("Project-ID")),
+			flags:     FlagsFromCtx(c),
 		}
 
 		response := ResponseData{
@@ -220,8 +272,13 @@ func (r *RequestHandlerBuilder[P, Q, B]) Build() fiber.Handler {
 			return c.Status(api_errors.ErrInvalidParams.StatusCode).JSON(response)
 		}
 
+		settings := r.settings
+		if settings == nil {
+			settings = SettingsFromCtx(c)
+		}
+
 		var accessErr *api_errors.Error
-		data.AccessId, data.AccessPermissions, accessErr = r.AccessLevel.CheckAccess(c)
+		data.AccessId, data.AccessPermissions, accessErr = r.AccessLevel.CheckAccessWithSettings(settings, c)
 		if accessErr != nil {
 			response.Message = accessErr.Message
 			response.Duration = time.Now().UnixMilli() - start
@@ -290,18 +347,28 @@ func (r *RequestHandlerBuilder[P, Q, B]) Build() fiber.Handler {
 			data.Pagination = &Pagination{
 				Limit:  perPage + 1,
 				Offset: (page - 1) * perPage,
+				Cursor: c.Query("cursor"),
 			}
 
 		}
 
+		userCtx := common.WithAccessPermissions(common.WithAccessId(c.UserContext(), data.AccessId), data.AccessPermissions)
+		userCtx = common.WithCorrelationId(userCtx, c.Get(common.CorrelationIdHeader))
+		c.SetUserContext(common.WithProject(userCtx, data.ProjectID))
 		rData, err := r.Handler(data)
 
 		var apiError api_errors.Error
+		dbError, isDbError := db_errors.ToAPIError(err)
 		switch {
 		case errors.As(err, &apiError):
 			response.Message = apiError.Message
 			response.Duration = time.Now().UnixMilli() - start
 			return c.Status(apiError.StatusCode).JSON(response)
+		case isDbError:
+			log.Println("Database error executing handler", err)
+			response.Message = dbError.Message
+			response.Duration = time.Now().UnixMilli() - start
+			return c.Status(dbError.StatusCode).JSON(response)
 		case err != nil:
 			log.Println("Error executing handler", err)
 			response.Message = api_errors.ErrSomethingWentWrong.Message
@@ -327,6 +394,7 @@ func (r *RequestHandlerBuilder[P, Q, B]) Build() fiber.Handler {
 			result := reflect.ValueOf(response.Data)
 			response.HasNext = result.Len() == data.Pagination.Limit
 			if response.HasNext {
+				response.NextCursor = data.Pagination.NextCursor
 				response.Data = result.Slice(0, result.Len()-1).Interface()
 			} else {
 				response.Data = result.Interface()
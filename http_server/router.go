@@ -20,6 +20,8 @@ type Router struct {
 	isProd     bool
 	port       int
 	srvStarted bool
+	settings   *config.Settings
+	flags      FlagEvaluator
 }
 
 type RouterConfig struct {
@@ -30,6 +32,27 @@ type RouterConfig struct {
 	CompressionType   compress.Level `json:"compression_type"`
 	Port              int            `json:"port"`
 	GlobalCors        []string       `json:"global_cors"`
+
+	// Settings is the configuration used by the router and its handlers, defaults to config.Default().
+	Settings *config.Settings `json:"-"`
+
+	// Flags evaluates the feature flags exposed through RequestData.IsFlagEnabled, e.g. a flags.Registry.
+	Flags FlagEvaluator `json:"-"`
+}
+
+// settingsLocalKey is the fiber.Ctx local under which the Router stores its config.Settings.
+const settingsLocalKey = "go_common_settings"
+
+// flagsLocalKey is the fiber.Ctx local under which the Router stores its FlagEvaluator.
+const flagsLocalKey = "go_common_flags"
+
+// SettingsFromCtx returns the config.Settings injected by the Router serving the request,
+// or config.Default() if the request is not served by a Router.
+func SettingsFromCtx(c *fiber.Ctx) *config.Settings {
+	if settings, ok := c.Locals(settingsLocalKey).(*config.Settings); ok {
+		return settings
+	}
+	return config.Default()
 }
 
 func NewRouter(cfg RouterConfig) *Router {
@@ -39,10 +62,20 @@ func NewRouter(cfg RouterConfig) *Router {
 	fiberConfig.StrictRouting = cfg.StrictRouting
 
 	h := &Router{
-		App:  fiber.New(fiberConfig),
-		port: cfg.Port,
+		App:      fiber.New(fiberConfig),
+		port:     cfg.Port,
+		settings: config.OrDefault(cfg.Settings),
+		flags:    cfg.Flags,
 	}
 
+	h.Use(func(c *fiber.Ctx) error {
+		c.Locals(settingsLocalKey, h.settings)
+		if h.flags != nil {
+			c.Locals(flagsLocalKey, h.flags)
+		}
+		return c.Next()
+	})
+
 	if cfg.IsRecoveryEnabled {
 		h.Use(fiberRecover.New(fiberRecover.Config{
 			EnableStackTrace: true,
@@ -57,7 +90,7 @@ func NewRouter(cfg RouterConfig) *Router {
 	}
 
 	corsHosts := ""
-	if config.GetCurrentEnvironment() == config.Production {
+	if h.settings.GetCurrentEnvironment() == config.Production {
 		h.isProd = true
 	} else {
 		h.isProd = false
@@ -80,6 +113,16 @@ func NewRouter(cfg RouterConfig) *Router {
 	return h
 }
 
+// Settings returns the config.Settings used by the router.
+func (r *Router) Settings() *config.Settings {
+	return r.settings
+}
+
+// Flags returns the FlagEvaluator used by the router, nil if none was configured.
+func (r *Router) Flags() FlagEvaluator {
+	return r.flags
+}
+
 func (r *Router) StartAsync() {
 	if r.srvStarted {
 		return
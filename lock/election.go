@@ -0,0 +1,142 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shiroyaavish/go-common/logger"
+)
+
+// ElectionCallbacks are notified of the leadership changes of an Election.
+// It contains the following fields:
+// - OnElected: called when this instance becomes the leader, ctx is cancelled when the leadership is lost, start the leader work from it and return
+// - OnRevoked: called after the leadership is lost or given up, once ctx of OnElected is cancelled
+type ElectionCallbacks struct {
+	OnElected func(ctx context.Context, l Lock)
+	OnRevoked func()
+}
+
+// Election elects a single leader among the replicas of a service through a lock, for background jobs
+// that must only run once (monitors, relays, schedulers).
+//
+// Example usage:
+//
+//	election := lock.NewElection(locker, "ec2-monitor", lock.ElectionCallbacks{
+//	    OnElected: func(ctx context.Context, l lock.Lock) {
+//	        go monitor.Run(ctx)
+//	    },
+//	})
+//	election.Start(ctx)
+//	defer election.Stop()
+type Election struct {
+	locker        Locker
+	name          string
+	callbacks     ElectionCallbacks
+	retryInterval time.Duration
+	leader        atomic.Bool
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewElection creates an Election on the lock name of locker.
+func NewElection(locker Locker, name string, callbacks ElectionCallbacks) *Election {
+	return &Election{
+		locker:        locker,
+		name:          name,
+		callbacks:     callbacks,
+		retryInterval: defaultRetryInterval,
+	}
+}
+
+// WithRetryInterval sets how often a follower tries to become the leader, defaults to 1 second.
+func (e *Election) WithRetryInterval(interval time.Duration) *Election {
+	e.retryInterval = interval
+	return e
+}
+
+// IsLeader reports whether this instance is currently the leader.
+func (e *Election) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Start runs the election in the background until ctx is done or Stop is called. Calling Start twice has no effect.
+func (e *Election) Start(ctx context.Context) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stop != nil {
+		return
+	}
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.run(ctx, e.stop, e.done)
+}
+
+// Stop stops the election, giving up the leadership when held, and waits for OnRevoked to return.
+func (e *Election) Stop() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// run campaigns for the leadership until stopped.
+func (e *Election) run(ctx context.Context, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(e.retryInterval)
+	defer ticker.Stop()
+	for {
+		l, err := e.locker.TryLock(ctx, e.name)
+		switch {
+		case err == nil:
+			e.lead(ctx, stop, l)
+		case err != ErrNotAcquired:
+			logger.Error(err, "cannot campaign for leadership of "+e.name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lead holds the leadership until the lock is lost or the election stopped.
+func (e *Election) lead(ctx context.Context, stop chan struct{}, l Lock) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	e.leader.Store(true)
+	logger.Info("elected leader of %s", e.name)
+	if e.callbacks.OnElected != nil {
+		e.callbacks.OnElected(leaderCtx, l)
+	}
+
+	select {
+	case <-l.Lost():
+		logger.Warn("lost leadership of %s", e.name)
+	case <-ctx.Done():
+	case <-stop:
+	}
+
+	cancel()
+	e.leader.Store(false)
+	unlockCtx, unlockCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := l.Unlock(unlockCtx); err != nil && err != ErrNotHeld {
+		logger.Error(err, "cannot release leadership of "+e.name)
+	}
+	unlockCancel()
+	if e.callbacks.OnRevoked != nil {
+		e.callbacks.OnRevoked()
+	}
+}
@@ -0,0 +1,10 @@
+package lock
+
+import "github.com/shiroyaavish/go-common/errors"
+
+var (
+	// ErrNotAcquired is returned by TryLock when the lock is held by someone else.
+	ErrNotAcquired = errors.NewError(40901, "lock held by another owner")
+	// ErrNotHeld is returned by Unlock when the lock was lost or already released.
+	ErrNotHeld = errors.NewError(40902, "lock not held")
+)
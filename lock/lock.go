@@ -0,0 +1,53 @@
+// Package lock provides distributed locks shared by the replicas of a service, backed by Postgres advisory locks
+// or Redis, and a leader election built on them.
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+// defaultRetryInterval is how often Lock tries to take a busy lock unless configured otherwise.
+const defaultRetryInterval = time.Second
+
+// Locker acquires named distributed locks.
+type Locker interface {
+	// TryLock takes the lock without waiting, returning ErrNotAcquired when it is held elsewhere.
+	TryLock(ctx context.Context, name string) (Lock, error)
+	// Lock waits until the lock is taken or ctx is done.
+	Lock(ctx context.Context, name string) (Lock, error)
+}
+
+// Lock is a held distributed lock.
+type Lock interface {
+	// Name returns the name of the lock.
+	Name() string
+	// Token returns the fencing token of the lock, greater than the tokens of every previous holder.
+	// Pass it to the protected resource so that it can reject writes from a holder that lost the lock meanwhile.
+	Token() int64
+	// Lost returns a channel closed when the lock is lost (connection loss, failed renewal) or released.
+	Lost() <-chan struct{}
+	// Unlock releases the lock, returning ErrNotHeld when it was already lost.
+	Unlock(ctx context.Context) error
+}
+
+// waitLock calls try every interval until it takes the lock, fails with another error than ErrNotAcquired,
+// or ctx is done.
+func waitLock(ctx context.Context, interval time.Duration, try func() (Lock, error)) (Lock, error) {
+	if interval <= 0 {
+		interval = defaultRetryInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		l, err := try()
+		if err != ErrNotAcquired {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/shiroyaavish/go-common/db"
+	"github.com/shiroyaavish/go-common/logger"
+)
+
+// PostgresOptions configures a PostgresLocker.
+// It contains the following fields:
+// - RetryInterval: how often Lock tries to take a busy lock, defaults to 1 second
+// - CheckInterval: how often the session holding a lock is checked, the lock is lost when it is gone, defaults to 5 seconds
+type PostgresOptions struct {
+	RetryInterval time.Duration
+	CheckInterval time.Duration
+}
+
+// PostgresLocker is a Locker backed by Postgres session advisory locks (see db.AdvisoryLockID).
+//
+// Each held lock pins a dedicated connection of the primary pool, so MaxOpenConns must leave room for them.
+// A lock is released by Postgres as soon as its session ends, which makes it safe against crashed holders.
+// Fencing tokens are transaction IDs taken after acquiring the lock, they only grow.
+//
+// Example usage:
+//
+//	locker := lock.NewPostgresLocker(conn, lock.PostgresOptions{})
+//	l, err := locker.TryLock(ctx, "ec2-monitor")
+//	if errors.Is(err, lock.ErrNotAcquired) {
+//	    return nil // another replica runs it
+//	}
+//	defer l.Unlock(ctx)
+type PostgresLocker struct {
+	conn    *db.DatabaseConnection
+	options PostgresOptions
+}
+
+// NewPostgresLocker creates a PostgresLocker on the primary of conn.
+func NewPostgresLocker(conn *db.DatabaseConnection, options PostgresOptions) *PostgresLocker {
+	if options.CheckInterval <= 0 {
+		options.CheckInterval = 5 * time.Second
+	}
+	return &PostgresLocker{
+		conn:    conn,
+		options: options,
+	}
+}
+
+// TryLock implements Locker.
+func (p *PostgresLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	sqlDB, err := p.conn.Raw().DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := db.AdvisoryLockID("go_common:lock:" + name)
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, ErrNotAcquired
+	}
+
+	var token int64
+	if err := conn.QueryRowContext(ctx, "SELECT txid_current()").Scan(&token); err != nil {
+		_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		_ = conn.Close()
+		return nil, err
+	}
+
+	l := &postgresLock{
+		name:  name,
+		key:   key,
+		token: token,
+		conn:  conn,
+		lost:  make(chan struct{}),
+		stop:  make(chan struct{}),
+	}
+	go l.watch(p.options.CheckInterval)
+	return l, nil
+}
+
+// Lock implements Locker.
+func (p *PostgresLocker) Lock(ctx context.Context, name string) (Lock, error) {
+	return waitLock(ctx, p.options.RetryInterval, func() (Lock, error) {
+		return p.TryLock(ctx, name)
+	})
+}
+
+// postgresLock is a held advisory lock and the session holding it.
+type postgresLock struct {
+	name  string
+	key   int64
+	token int64
+
+	mu       sync.Mutex
+	conn     *sql.Conn
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+}
+
+// Name implements Lock.
+func (l *postgresLock) Name() string {
+	return l.name
+}
+
+// Token implements Lock.
+func (l *postgresLock) Token() int64 {
+	return l.token
+}
+
+// Lost implements Lock.
+func (l *postgresLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock implements Lock.
+func (l *postgresLock) Unlock(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return ErrNotHeld
+	}
+	close(l.stop)
+
+	var unlocked bool
+	err := l.conn.QueryRowContext(ctx, "SELECT pg_advisory_unlock($1)", l.key).Scan(&unlocked)
+	_ = l.conn.Close()
+	l.conn = nil
+	l.markLost()
+	if err != nil {
+		return err
+	}
+	if !unlocked {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// watch pings the session holding the lock every interval, the lock is lost when the session is gone.
+func (l *postgresLock) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		l.mu.Lock()
+		if l.conn == nil {
+			l.mu.Unlock()
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		err := l.conn.PingContext(ctx)
+		cancel()
+		if err != nil {
+			logger.Error(err, "lost the session holding lock "+l.name)
+			_ = l.conn.Close()
+			l.conn = nil
+			l.markLost()
+			l.mu.Unlock()
+			return
+		}
+		l.mu.Unlock()
+	}
+}
+
+// markLost closes the lost channel once.
+func (l *postgresLock) markLost() {
+	l.lostOnce.Do(func() {
+		close(l.lost)
+	})
+}
@@ -0,0 +1,210 @@
+package lock
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/shiroyaavish/go-common/logger"
+	"github.com/shiroyaavish/go-common/redis"
+)
+
+// DefaultRedisKeyPrefix prefixes the keys of the locks held in Redis.
+const DefaultRedisKeyPrefix = "go_common:lock:"
+
+// renewScript extends the expiry of a lock when it is still held with the given token.
+var renewScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// unlockScript deletes a lock when it is still held with the given token.
+var unlockScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisOptions configures a RedisLocker.
+// It contains the following fields:
+// - TTL: how long a lock survives without renewal, bounding how long a crashed holder blocks others, defaults to 30 seconds
+// - RenewInterval: how often held locks are renewed, defaults to a third of TTL
+// - RetryInterval: how often Lock tries to take a busy lock, defaults to 1 second
+// - KeyPrefix: the prefix of the lock keys, defaults to DefaultRedisKeyPrefix
+type RedisOptions struct {
+	TTL           time.Duration
+	RenewInterval time.Duration
+	RetryInterval time.Duration
+	KeyPrefix     string
+}
+
+// RedisLocker is a Locker backed by Redis keys set with SET NX and an expiry, renewed in the background while held.
+//
+// Fencing tokens come from a counter incremented on every acquisition, stored next to the lock key.
+// A lock is lost when a renewal finds it taken over or cannot reach Redis before the TTL elapses.
+// It relies on a single Redis primary: a failover losing the key can let two holders in, which the fencing
+// token lets the protected resource detect.
+//
+// Example usage:
+//
+//	locker := lock.NewRedisLocker(redisClient, lock.RedisOptions{TTL: 15 * time.Second})
+//	l, err := locker.Lock(ctx, "sqs-relay")
+//	if err != nil {
+//	    return err
+//	}
+//	defer l.Unlock(ctx)
+type RedisLocker struct {
+	client  *redis.RedisClient
+	options RedisOptions
+}
+
+// NewRedisLocker creates a RedisLocker on client.
+func NewRedisLocker(client *redis.RedisClient, options RedisOptions) *RedisLocker {
+	if options.TTL <= 0 {
+		options.TTL = 30 * time.Second
+	}
+	if options.RenewInterval <= 0 || options.RenewInterval >= options.TTL {
+		options.RenewInterval = options.TTL / 3
+	}
+	if options.KeyPrefix == "" {
+		options.KeyPrefix = DefaultRedisKeyPrefix
+	}
+	return &RedisLocker{
+		client:  client,
+		options: options,
+	}
+}
+
+// TryLock implements Locker.
+func (r *RedisLocker) TryLock(ctx context.Context, name string) (Lock, error) {
+	key := r.options.KeyPrefix + name
+	token, err := r.client.Raw().Incr(ctx, key+":fence").Result()
+	if err != nil {
+		return nil, err
+	}
+	acquired, err := r.client.Raw().SetNX(ctx, key, strconv.FormatInt(token, 10), r.options.TTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !acquired {
+		return nil, ErrNotAcquired
+	}
+
+	l := &redisLock{
+		locker:  r,
+		name:    name,
+		key:     key,
+		token:   token,
+		renewed: time.Now(),
+		lost:    make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	go l.renew()
+	return l, nil
+}
+
+// Lock implements Locker.
+func (r *RedisLocker) Lock(ctx context.Context, name string) (Lock, error) {
+	return waitLock(ctx, r.options.RetryInterval, func() (Lock, error) {
+		return r.TryLock(ctx, name)
+	})
+}
+
+// redisLock is a lock held in Redis.
+type redisLock struct {
+	locker  *RedisLocker
+	name    string
+	key     string
+	token   int64
+	renewed time.Time
+
+	lost     chan struct{}
+	lostOnce sync.Once
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Name implements Lock.
+func (l *redisLock) Name() string {
+	return l.name
+}
+
+// Token implements Lock.
+func (l *redisLock) Token() int64 {
+	return l.token
+}
+
+// Lost implements Lock.
+func (l *redisLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+// Unlock implements Lock.
+func (l *redisLock) Unlock(ctx context.Context) error {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+	defer l.markLost()
+
+	deleted, err := unlockScript.Run(ctx, l.locker.client.Raw(), []string{l.key}, strconv.FormatInt(l.token, 10)).Int()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// renew extends the lock every RenewInterval until it is released or lost.
+// Failed renewals are retried at the next interval, the lock is given up when the next retry would come after it expires.
+func (l *redisLock) renew() {
+	options := l.locker.options
+	ticker := time.NewTicker(options.RenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), options.RenewInterval)
+		renewed, err := renewScript.Run(ctx, l.locker.client.Raw(), []string{l.key},
+			strconv.FormatInt(l.token, 10), options.TTL.Milliseconds()).Int()
+		cancel()
+
+		select {
+		case <-l.stop:
+			// Released while renewing
+			return
+		default:
+		}
+		switch {
+		case err == nil && renewed == 1:
+			l.renewed = time.Now()
+		case err == nil:
+			logger.Warn("lock %s was taken over", l.name)
+			l.markLost()
+			return
+		case time.Since(l.renewed)+options.RenewInterval >= options.TTL:
+			logger.Error(err, "cannot renew lock "+l.name+" before it expires, giving it up")
+			l.markLost()
+			return
+		default:
+			logger.Error(err, "cannot renew lock "+l.name+", retrying")
+		}
+	}
+}
+
+// markLost closes the lost channel once.
+func (l *redisLock) markLost() {
+	l.lostOnce.Do(func() {
+		close(l.lost)
+	})
+}
@@ -33,7 +33,7 @@ func Errorf(format string, v ...interface{}) {
 
 // Error provides error level log
 func Error(err error, msg ...string) {
-	Logger.Error().Msgf(fmt.Sprintf("%s\n %s", strings.Join(msg, "\n"), err.Error()))
+	Logger.Error().Msg(fmt.Sprintf("%s\n %s", strings.Join(msg, "\n"), err.Error()))
 }
 
 func Fatal(err error, msg ...string) {
@@ -43,7 +43,7 @@ func Fatal(err error, msg ...string) {
 
 func Data(data interface{}) {
 	v, _ := json.Marshal(data)
-	Logger.Info().Msgf(fmt.Sprintf("Data: %s", string(v)))
+	Logger.Info().Msg(fmt.Sprintf("Data: %s", string(v)))
 }
 
 // Warn function logs a warning message using the provided format and arguments.